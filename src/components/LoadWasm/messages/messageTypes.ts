@@ -20,6 +20,12 @@ export enum MessageTypes {
   BLOCK_CREATED = "blockCreated",
   INSERT_BLOCK = "insertBlock",
   BLOCK_INSERTED = "blockInserted",
+  MINING_PROGRESS = "miningProgress",
+
+  START_AUTO_MINING = "startAutoMining",
+  AUTO_MINING_STARTED = "autoMiningStarted",
+  STOP_AUTO_MINING = "stopAutoMining",
+  AUTO_MINING_STOPPED = "autoMiningStopped",
 
   CREATE_KEY_PAIR = "createKeyPair",
   KEY_PAIR_CREATED = "keyPairCreated",
@@ -1,12 +1,14 @@
 declare global {
   export type TxCandidate = {
     readonly amount: number;
+    // fee goes to the miner. 0 if omitted.
+    readonly fee?: number;
     readonly dstAddress: string;
     readonly privateKey: string;
   };
 
   export type BlockCandidate = {
-    readonly transactionHashes: string[];
+    readonly transactionHashes?: string[];
   };
 
   export type TxIn = {
@@ -23,6 +25,7 @@ declare global {
   export type Transaction = {
     hash: string;
     createdAt: string;
+    nonce?: number;
     inputs: TxIn[];
     outputs: TxOut[];
   };
@@ -35,6 +38,7 @@ declare global {
   };
 
   export type BlockHeader = {
+    version: number;
     curHash: string;
     prevHash: string;
     dataHash: string;
@@ -48,6 +52,87 @@ declare global {
     body: BlockBody;
   };
 
+  export type FeeBucket = {
+    minFeeRate: number;
+    count: number;
+    size: number;
+  };
+
+  export type Mint = {
+    txHash: string;
+    blockHash: string;
+    nonce: number;
+    outputs: TxOut[];
+    amount: number;
+    mintedAt: string;
+  };
+
+  export type Deployment = {
+    name: string;
+    bit: number;
+    state: "defined" | "locked_in" | "active";
+    count: number;
+  };
+
+  export type MiningBackend = "auto" | "cpu" | "gpu";
+
+  // dispatch geometry of the GPU miner. zero fields take their defaults.
+  export type GPUConfig = {
+    workgroups: number;
+    batchSize: number;
+    depth: number;
+  };
+
+  // limits of the CPU miner. workers 0 uses every worker, and dutyCycle 0
+  // is the same as 1, hashing all the time.
+  export type CPUThrottle = {
+    workers: number;
+    dutyCycle: number;
+  };
+
+  // durations are in nanoseconds.
+  export type MiningProgress = {
+    hashes: number;
+    hashRate: number;
+    nonceStart: number;
+    nonceEnd: number;
+    elapsed: number;
+    expectedTime: number;
+  };
+
+  // proof-of-work hash of the header is the hash by algorithm of hashInput
+  // followed by the little endian uint32 nonce, and should not be greater
+  // than target.
+  export type BlockTemplate = {
+    version: number;
+    prevHash: string;
+    dataHash: string;
+    timestamp: string;
+    difficulty: number;
+    target: string;
+    algorithm: "sha256" | "sha256d" | "scrypt";
+    hashInput: string;
+    coinbaseTx: Transaction;
+    txs: Transaction[];
+  };
+
+  export type PoolTemplate = BlockTemplate & {
+    shareDifficulty: number;
+    shareTarget: string;
+  };
+
+  export type ShareResult = {
+    isBlock: boolean;
+    block?: Block;
+    // template paying the shares including this one, if it is not a block.
+    template?: PoolTemplate;
+  };
+
+  export type WorkerShares = {
+    addr: string;
+    shares: number;
+  };
+
   export type KeyPair = {
     publicKey: string;
     privateKey: string;
@@ -56,6 +141,8 @@ declare global {
   export interface Window {
     Go: any;
     createBlock: (input: BlockCandidate) => Promise<Block>;
+    // resolves with null if there is no checkpoint to resume on the head.
+    resumeMining: () => Promise<Block | null>;
     createNewTx: (input: TxCandidate) => Promise<Transaction>;
     insertBroadcastedBlock: (candidate: Block) => Promise<void>;
     insertBroadcastedTx: (candidate: Transaction) => Promise<void>;
@@ -64,6 +151,26 @@ declare global {
     getHeadHash: () => Promise<string>;
     setHeadHash: (head: string) => Promise<void>;
     getBalance: (addr: string) => Promise<number>;
+    estimateFee: (targetBlocks: number) => Promise<number>;
+    getMempoolFeeHistogram: () => Promise<FeeBucket[]>;
+    getMints: () => Promise<Mint[]>;
+    setParentRequester: (requester: (parentHash: string) => void) => Promise<void>;
+    getDeployments: () => Promise<Deployment[]>;
+    setMiningBackend: (backend?: MiningBackend) => Promise<MiningBackend>;
+    getMiningBackend: () => Promise<MiningBackend>;
+    setGPUConfig: (config: Partial<GPUConfig>) => Promise<void>;
+    getGPUConfig: () => Promise<GPUConfig>;
+    setCPUThrottle: (throttle: Partial<CPUThrottle>) => Promise<void>;
+    getCPUThrottle: () => Promise<CPUThrottle>;
+    setMiningProgressListener: (listener: (progress: MiningProgress) => void) => Promise<void>;
+    startAutoMining: (announcer: (block: Block) => void) => Promise<void>;
+    stopAutoMining: () => Promise<void>;
+    getBlockTemplate: () => Promise<BlockTemplate>;
+    submitBlock: (header: Omit<BlockHeader, "curHash" | "nonce">, nonce: number) => Promise<Block>;
+    getPoolTemplate: () => Promise<PoolTemplate>;
+    submitShare: (worker: string, header: Omit<BlockHeader, "curHash" | "nonce">, nonce: number) => Promise<ShareResult>;
+    getPoolShares: () => Promise<WorkerShares[]>;
+    mineShare: (template: PoolTemplate) => Promise<number>;
 
     getDevice: () => any;
   }
@@ -3,10 +3,27 @@ import "./wasmTypes.d.ts";
 import "./wasm_exec.js";
 
 async function initWasmWorker() {
+  // the miner selects its backend on start, so the device should be ready before.
+  await initGPU();
+
   const goWasm = new self.Go();
   const result = await WebAssembly.instantiateStreaming(fetch("/main.wasm"), goWasm.importObject);
   goWasm.run(result.instance);
 
+  await self.setMiningProgressListener((progress: MiningProgress) => {
+    postMessage(new Message(MessageTypes.MINING_PROGRESS, progress));
+  });
+
+  // a block checkpointed before the page reloaded is reported as if createBlock created it.
+  self
+    .resumeMining()
+    .then((block) => {
+      if (block) {
+        postMessage(new Message(MessageTypes.BLOCK_CREATED, block));
+      }
+    })
+    .catch((err) => console.warn("failed to resume mining:", err));
+
   onmessage = async (event: MessageEvent<Message<unknown>>): Promise<void> => {
     try {
       switch (event.data.type) {
@@ -20,6 +37,19 @@ async function initWasmWorker() {
           postMessage(new Message(MessageTypes.BLOCK_CREATED, val));
           break;
         }
+        case MessageTypes.START_AUTO_MINING: {
+          // every mined block is reported as if createBlock created it.
+          await self.startAutoMining((block: Block) => {
+            postMessage(new Message(MessageTypes.BLOCK_CREATED, block));
+          });
+          postMessage(new Message(MessageTypes.AUTO_MINING_STARTED, {}));
+          break;
+        }
+        case MessageTypes.STOP_AUTO_MINING: {
+          await self.stopAutoMining();
+          postMessage(new Message(MessageTypes.AUTO_MINING_STOPPED, {}));
+          break;
+        }
         case MessageTypes.INSERT_TX: {
           await self.insertBroadcastedTx(event.data.data as Transaction);
           postMessage(new Message(MessageTypes.TX_INSERTED, {}));
@@ -61,26 +91,29 @@ async function initWasmWorker() {
     }
   };
 
-  await initGPU();
-
   postMessage({});
 }
 
+// initGPU exposes the WebGPU device through getDevice.
+// Without WebGPU, the miner falls back to the CPU.
 async function initGPU() {
   if (!navigator.gpu) {
-    throw new Error("WebGPU not suppored :(");
+    console.warn("WebGPU not suppored, mining on CPU");
+    return;
   }
 
   const adapter = await navigator.gpu.requestAdapter({
     powerPreference: "high-performance",
   });
   if (!adapter) {
-    throw new Error("No WebGPU adapter available :(");
+    console.warn("No WebGPU adapter available, mining on CPU");
+    return;
   }
 
   const device = await adapter.requestDevice();
   if (!device) {
-    throw new Error("No Device available :(");
+    console.warn("No Device available, mining on CPU");
+    return;
   }
 
   self.getDevice = () => {
@@ -0,0 +1,89 @@
+// Command bench measures the miners against fixed header inputs, and writes
+// the report as JSON, so that the reports of releases can be compared:
+//
+//	go run ./cmd/bench -miners cpu,emulator -difficulties 8,12,16 > report.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"miner/internal/bench"
+	"miner/internal/blockchain"
+	"miner/internal/processor"
+)
+
+func main() {
+	var (
+		miners       = flag.String("miners", bench.MinerCPU+","+bench.MinerEmulator, "comma separated miners to run")
+		algorithm    = flag.String("algorithm", blockchain.PowAlgorithm, "proof-of-work algorithm")
+		difficulties = flag.String("difficulties", "8,12,16", "comma separated difficulties to solve")
+		samples      = flag.Int("samples", 20, "headers solved per difficulty")
+		hashDuration = flag.Duration("hash-duration", 2*time.Second, "how long the hash rate is measured for")
+		workers      = flag.Int("workers", 0, "workers of the CPU miner, GOMAXPROCS if 0")
+		batchSize    = flag.Uint("cpu-batch-size", 0, "nonces a CPU worker claims at once")
+		workgroups   = flag.Uint("workgroups", 0, "workgroups of a kernel dispatch")
+		kernelBatch  = flag.Uint("kernel-batch-size", 0, "nonces a kernel invocation hashes")
+		depth        = flag.Int("depth", 0, "kernel dispatches in flight")
+		out          = flag.String("o", "", "file to write the report to, stdout if empty")
+	)
+	flag.Parse()
+
+	cfg := bench.Config{
+		Miners:       strings.Split(*miners, ","),
+		Algorithm:    *algorithm,
+		Samples:      *samples,
+		HashDuration: *hashDuration,
+		CPUWorkers:   *workers,
+		CPUBatchSize: uint32(*batchSize),
+		Kernel: processor.KernelConfig{
+			Workgroups: uint32(*workgroups),
+			BatchSize:  uint32(*kernelBatch),
+			Depth:      *depth,
+		},
+	}
+
+	for _, s := range strings.Split(*difficulties, ",") {
+		d, err := strconv.Atoi(s)
+		if err != nil {
+			fail(fmt.Errorf("invalid difficulty %q: %w", s, err))
+		}
+		cfg.Difficulties = append(cfg.Difficulties, d)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := bench.Run(ctx, cfg)
+	if err != nil {
+		fail(err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "bench:", err)
+	os.Exit(1)
+}
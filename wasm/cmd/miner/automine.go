@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"syscall/js"
+
+	"miner/internal/automine"
+	"miner/internal/block"
+	"miner/internal/misc/console"
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+)
+
+var (
+	blockAnnouncer js.Value
+	autoMiner      = automine.New(automine.Config{
+		Build: func(ctx context.Context) (*block.Block, error) {
+			return chainNode.NewBlockTemplate(ctx, nil)
+		},
+		Mine:   mineBlock,
+		Commit: commitMinedBlock,
+		OnError: func(err error) {
+			console.Warn("auto-mining failed:", err.Error())
+		},
+	})
+)
+
+// startAutoMining starts mining blocks continuously. The JS function given is
+// called with every block mined, so that it can be broadcasted.
+func startAutoMining() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			if args[0].Type() != js.TypeFunction {
+				return reject.Invoke("announcer should be a function")
+			}
+
+			blockAnnouncer = args[0]
+
+			if err := autoMiner.Start(); err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			return resolve.Invoke()
+		}))
+	})
+}
+
+func stopAutoMining() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			autoMiner.Stop()
+			return resolve.Invoke()
+		}))
+	})
+}
+
+// commitMinedBlock connects the block mined by the auto-miner and announces it.
+func commitMinedBlock(ctx context.Context, b *block.Block) error {
+	if err := chainNode.CommitMinedBlock(ctx, b); err != nil {
+		return err
+	}
+
+	announced := *b.Body
+	announced.CoinbaseTxHash = nil
+	announced.TxHashes = nil
+
+	if blockAnnouncer.Type() == js.TypeFunction {
+		data, _ := json.Marshal(&block.Block{Header: b.Header, Body: &announced})
+		blockAnnouncer.Invoke(util.ToJSObject(data))
+	}
+
+	return nil
+}
+
+// mempoolChanged lets the auto-miner restart with a better mempool.
+func mempoolChanged() {
+	autoMiner.MempoolChanged(chainNode.MempoolValue())
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"miner/internal/misc/console"
+	"miner/internal/node"
+)
+
+// chainNode is the full node backed by the browser storage. It is created in
+// main, since its hooks refer back to the miners.
+var chainNode *node.Node
+
+// powAlgorithm is the proof-of-work algorithm of the chain parameters.
+var powAlgorithm = node.ChainAlgorithm()
+
+func newChainNode() *node.Node {
+	return node.New(node.Config{
+		OnHeadChanged:    headChanged,
+		OnMempoolChanged: mempoolChanged,
+		RequestParent:    requestParent,
+		OnError: func(err error) {
+			console.Warn(err.Error())
+		},
+	})
+}
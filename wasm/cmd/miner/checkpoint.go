@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/checkpoint"
+	"miner/internal/misc/console"
+	"miner/internal/misc/promise"
+	"miner/internal/processor"
+	"miner/internal/storage"
+)
+
+// checkpointInterval is the interval the progress of createBlock is saved in.
+const checkpointInterval = 10 * time.Second
+
+var (
+	checkpointMu sync.Mutex
+	// resumable is the checkpoint found on startup which can resume on the head.
+	resumable *checkpoint.Checkpoint
+)
+
+// loadCheckpoint loads the mining checkpoint, and deletes it if it cannot
+// resume on the current head.
+func loadCheckpoint(ctx context.Context) error {
+	cp, err := storage.FindCheckpoint(ctx)
+	if err != nil || cp == nil {
+		return err
+	}
+
+	if err := cp.Check(blockchain.HeadHash, chainNode.Difficulty()); err != nil {
+		console.Warn("discarding mining checkpoint:", err.Error())
+		return storage.DeleteCheckpoint(ctx)
+	}
+
+	checkpointMu.Lock()
+	resumable = cp
+	checkpointMu.Unlock()
+
+	return nil
+}
+
+// saveCheckpoints saves the progress of mining b periodically until the
+// returned function is called, which deletes the checkpoint.
+func saveCheckpoints(mu *sync.Mutex, b *block.Block, telemetry *processor.Telemetry) (stop func()) {
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				cp := checkpoint.New(b, telemetry.Searched())
+				mu.Unlock()
+
+				if err := storage.PutCheckpoint(ctx, cp); err != nil {
+					console.Warn("failed to save mining checkpoint:", err.Error())
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+
+		if err := storage.DeleteCheckpoint(ctx); err != nil {
+			console.Warn("failed to delete mining checkpoint:", err.Error())
+		}
+	}
+}
+
+// resumeMining resumes mining the block checkpointed before the page reloaded,
+// and resolves with the block like createBlock. It resolves with null if there
+// is nothing to resume.
+func resumeMining() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			checkpointMu.Lock()
+			cp := resumable
+			resumable = nil
+			checkpointMu.Unlock()
+
+			if cp == nil {
+				return resolve.Invoke(js.Null())
+			}
+
+			ctx := context.Background()
+			// taken before the check, so that mining stops if the head changes meanwhile.
+			miningCtx := miningContext()
+
+			if err := cp.Check(blockchain.HeadHash, chainNode.Difficulty()); err != nil {
+				if err := storage.DeleteCheckpoint(ctx); err != nil {
+					console.Warn("failed to delete mining checkpoint:", err.Error())
+				}
+				return resolve.Invoke(js.Null())
+			}
+
+			b, start, err := cp.Resume()
+			if err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			if err := mineCheckpointed(miningCtx, b, start); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to mine block: %v", err))
+			}
+
+			// the transactions may be spent by now, so the block is validated.
+			if err := chainNode.CommitMinedBlock(ctx, b); err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			return resolve.Invoke(minedBlockObject(b))
+		}))
+	})
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+)
+
+func getDeployments() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			b, _ := json.Marshal(chainNode.Deployments.Statuses())
+			return resolve.Invoke(util.ToJSObject(b))
+		}))
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"miner/internal/mempool"
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+)
+
+func estimateFee() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			targetBlocks := 1
+			if len(args) > 0 && args[0].Type() == js.TypeNumber {
+				targetBlocks = args[0].Int()
+			}
+
+			return resolve.Invoke(chainNode.FeeEstimator.EstimateFee(targetBlocks, chainNode.FeeIndex))
+		}))
+	})
+}
+
+func getMempoolFeeHistogram() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			b, _ := json.Marshal(chainNode.FeeIndex.Histogram(mempool.DefaultBuckets))
+			return resolve.Invoke(util.ToJSObject(b))
+		}))
+	})
+}
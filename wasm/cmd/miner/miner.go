@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"miner/internal/blockchain"
+	"miner/internal/processor"
 	"miner/internal/storage"
 	"syscall/js"
 )
@@ -14,14 +14,22 @@ func main() {
 		panic(err)
 	}
 
-	hash, err := storage.FindBlockchainHead()
-	if err != nil {
+	chainNode = newChainNode()
+	if err := chainNode.Load(ctx); err != nil {
+		panic(err)
+	}
+
+	if err := loadCheckpoint(ctx); err != nil {
+		panic(err)
+	}
+
+	if err := selectMiner(processor.BackendAuto); err != nil {
 		panic(err)
 	}
-	blockchain.HeadHash = hash
 
 	js.Global().Set("createNewTx", createNewTx())
 	js.Global().Set("createBlock", createBlock())
+	js.Global().Set("resumeMining", resumeMining())
 	js.Global().Set("insertBroadcastedTx", insertBroadcastedTx())
 	js.Global().Set("insertBroadcastedBlock", insertBroadcastedBlock())
 	js.Global().Set("createKeyPair", createKeyPair())
@@ -29,6 +37,26 @@ func main() {
 	js.Global().Set("getHeadHash", getHeadHash())
 	js.Global().Set("setHeadHash", setHeadHash())
 	js.Global().Set("getBalance", getBalance())
+	js.Global().Set("estimateFee", estimateFee())
+	js.Global().Set("getMempoolFeeHistogram", getMempoolFeeHistogram())
+	js.Global().Set("getMints", getMints())
+	js.Global().Set("setParentRequester", setParentRequester())
+	js.Global().Set("getDeployments", getDeployments())
+	js.Global().Set("setMiningBackend", setMiningBackend())
+	js.Global().Set("getMiningBackend", getMiningBackend())
+	js.Global().Set("setGPUConfig", setGPUConfig())
+	js.Global().Set("getGPUConfig", getGPUConfig())
+	js.Global().Set("setCPUThrottle", setCPUThrottle())
+	js.Global().Set("getCPUThrottle", getCPUThrottle())
+	js.Global().Set("setMiningProgressListener", setMiningProgressListener())
+	js.Global().Set("startAutoMining", startAutoMining())
+	js.Global().Set("stopAutoMining", stopAutoMining())
+	js.Global().Set("getBlockTemplate", getBlockTemplate())
+	js.Global().Set("submitBlock", submitBlock())
+	js.Global().Set("getPoolTemplate", getPoolTemplate())
+	js.Global().Set("submitShare", submitShare())
+	js.Global().Set("getPoolShares", getPoolShares())
+	js.Global().Set("mineShare", mineShare())
 
 	select {}
 }
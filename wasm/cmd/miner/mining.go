@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+	"miner/internal/processor"
+)
+
+var (
+	miner         processor.Miner
+	miningBackend processor.Backend
+	// gpuConfig is the dispatch geometry of the GPU miner.
+	gpuConfig processor.KernelConfig
+	// cpuThrottle limits the CPU miner, also while it runs.
+	cpuThrottle = &processor.Throttle{}
+
+	progressListener js.Value
+
+	miningMu     sync.Mutex
+	miningCtx    context.Context
+	cancelMining context.CancelFunc
+)
+
+func init() {
+	miningCtx, cancelMining = context.WithCancel(context.Background())
+}
+
+// miningContext returns the context for mining on the current head.
+// It is cancelled when the head changes, since the template is stale then.
+func miningContext() context.Context {
+	miningMu.Lock()
+	defer miningMu.Unlock()
+
+	return miningCtx
+}
+
+// headChanged cancels mining on the previous head.
+func headChanged() {
+	miningMu.Lock()
+	defer miningMu.Unlock()
+
+	cancelMining()
+	miningCtx, cancelMining = context.WithCancel(context.Background())
+
+	autoMiner.HeadChanged()
+}
+
+// selectMiner selects the miner of backend. The current miner is kept on error.
+func selectMiner(backend processor.Backend) error {
+	m, selected, err := processor.NewMiner(backend, powAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	switch selectedMiner := m.(type) {
+	case processor.KernelMiner:
+		selectedMiner.Config = gpuConfig
+		m = selectedMiner
+	case processor.CPUMiner:
+		selectedMiner.Throttle = cpuThrottle
+		m = selectedMiner
+	}
+
+	miner, miningBackend = m, selected
+	return nil
+}
+
+func setMiningBackend() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			backend := processor.BackendAuto
+			if len(args) > 0 && args[0].Truthy() {
+				backend = processor.Backend(args[0].String())
+			}
+
+			if err := selectMiner(backend); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to select mining backend: %v", err))
+			}
+
+			return resolve.Invoke(string(miningBackend))
+		}))
+	})
+}
+
+func getMiningBackend() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			return resolve.Invoke(string(miningBackend))
+		}))
+	})
+}
+
+// setGPUConfig sets the dispatch geometry of the GPU miner. Zero fields
+// take their defaults. It applies from the next mining.
+func setGPUConfig() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			var cfg processor.KernelConfig
+			if err := json.Unmarshal(util.FromJSObject(args[0]), &cfg); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to unmarshal GPU config: %v", err))
+			}
+
+			if err := cfg.Validate(); err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			gpuConfig = cfg
+			if err := selectMiner(miningBackend); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to apply GPU config: %v", err))
+			}
+
+			return resolve.Invoke()
+		}))
+	})
+}
+
+func getGPUConfig() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			b, _ := json.Marshal(gpuConfig.WithDefaults())
+			return resolve.Invoke(util.ToJSObject(b))
+		}))
+	})
+}
+
+// setCPUThrottle sets the worker count and the duty cycle of the CPU miner,
+// which the running searches apply at once. Zero fields do not limit.
+func setCPUThrottle() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			var cfg processor.ThrottleConfig
+			if err := json.Unmarshal(util.FromJSObject(args[0]), &cfg); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to unmarshal CPU throttle: %v", err))
+			}
+
+			if err := cpuThrottle.Set(cfg); err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			return resolve.Invoke()
+		}))
+	})
+}
+
+func getCPUThrottle() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			b, _ := json.Marshal(cpuThrottle.Config())
+			return resolve.Invoke(util.ToJSObject(b))
+		}))
+	})
+}
+
+// progressInterval is the interval the progress listener is called in.
+const progressInterval = time.Second
+
+// setMiningProgressListener sets the JS function which is called with the
+// progress of the running mining periodically, and once more when it ends.
+func setMiningProgressListener() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			if args[0].Type() != js.TypeFunction {
+				return reject.Invoke("listener should be a function")
+			}
+
+			progressListener = args[0]
+
+			return resolve.Invoke()
+		}))
+	})
+}
+
+// reportProgress reports the progress of telemetry to the listener
+// until the returned function is called.
+func reportProgress(telemetry *processor.Telemetry) (stop func()) {
+	report := func() {
+		if progressListener.Type() != js.TypeFunction {
+			return
+		}
+
+		b, _ := json.Marshal(telemetry.Progress())
+		progressListener.Invoke(util.ToJSObject(b))
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				report()
+				return
+			case <-ticker.C:
+				report()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// mineBlock finds the nonce of the block. When the header nonce space is used
+// up, the extra-nonce of the coinbase is bumped to search with fresh work.
+func mineBlock(ctx context.Context, b *block.Block) error {
+	return mine(ctx, b, 0, false)
+}
+
+// mineCheckpointed finds the nonce of the block from start like mineBlock,
+// and checkpoints the progress periodically, so that it can resume after the
+// page reloads. The checkpoint is deleted once mining stops. Since only a
+// checkpoint is kept, the one found on startup cannot be resumed afterwards.
+func mineCheckpointed(ctx context.Context, b *block.Block, start uint32) error {
+	checkpointMu.Lock()
+	resumable = nil
+	checkpointMu.Unlock()
+
+	return mine(ctx, b, start, true)
+}
+
+func mine(ctx context.Context, b *block.Block, start uint32, checkpointed bool) error {
+	telemetry := processor.NewTelemetry(b.Header.Difficulty)
+	telemetry.StartAt(start)
+
+	stop := reportProgress(telemetry)
+	defer stop()
+
+	// mu guards b against the checkpoints.
+	var mu sync.Mutex
+	if checkpointed {
+		stopCheckpoints := saveCheckpoints(&mu, b, telemetry)
+		defer stopCheckpoints()
+	}
+
+	for {
+		opts := processor.Options{StartNonce: start, Telemetry: telemetry}
+		r, err := miner.Mine(ctx, b.Header.MakeHashInput(), b.Header.Difficulty, opts)
+		if err == nil {
+			mu.Lock()
+			b.Header.Nonce = r.Nonce
+			b.Header.CurHash = b.Header.MakeHash()
+			mu.Unlock()
+			return nil
+		}
+
+		if !errors.Is(err, processor.ErrNonceNotFound) {
+			return err
+		}
+
+		mu.Lock()
+		err = b.IncrementExtraNonce()
+		start = 0
+		telemetry.StartAt(start)
+		mu.Unlock()
+
+		if err != nil {
+			return errors.Wrap(err, "failed to increment extra-nonce")
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"syscall/js"
+
+	"miner/internal/blockchain"
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+)
+
+func getMints() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			b, _ := json.Marshal(chainNode.MintLedger.Mints())
+			return resolve.Invoke(util.ToJSObject(b))
+		}))
+	})
+}
+
+func isAdminKey(privKey *ecdsa.PrivateKey) bool {
+	return privKey.PublicKey.Equal(blockchain.AdminPublicKey())
+}
+
+func newMintNonce() (nonce uint64, err error) {
+	err = binary.Read(rand.Reader, binary.LittleEndian, &nonce)
+	return
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"syscall/js"
+
+	"miner/internal/hash"
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+)
+
+var parentRequester js.Value
+
+// setParentRequester sets the JS function which is called with the hex hash
+// of a missing parent block whenever an orphan block arrives.
+func setParentRequester() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			if args[0].Type() != js.TypeFunction {
+				return reject.Invoke("requester should be a function")
+			}
+
+			parentRequester = args[0]
+
+			return resolve.Invoke()
+		}))
+	})
+}
+
+func requestParent(parentHash hash.Hash) {
+	if parentRequester.Type() != js.TypeFunction {
+		return
+	}
+	parentRequester.Invoke(util.BytesToStr(parentHash.ToHex()))
+}
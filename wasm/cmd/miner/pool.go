@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/blocktemplate"
+	"miner/internal/consensus"
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+	"miner/internal/pool"
+	"miner/internal/processor"
+)
+
+// shareDifficulty is the difficulty of the shares of the pool workers.
+const shareDifficulty = blockchain.Difficulty - 6
+
+var (
+	miningPool = pool.New(shareDifficulty, powAlgorithm)
+	// a template is created for every accepted share, so the shares of a
+	// worker which falls behind by the limit are rejected until it refreshes.
+	poolTemplates = blocktemplate.NewStore(blocktemplate.DefaultStoreLimit)
+)
+
+// getPoolTemplate returns the template for the pool workers. Its coinbase
+// splits the reward by the shares of the round at the time it is created,
+// so workers should move to the template given with every accepted share.
+func getPoolTemplate() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			tmpl, err := newPoolTemplate(context.Background())
+			if err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			data, _ := json.Marshal(tmpl)
+			return resolve.Invoke(util.ToJSObject(data))
+		}))
+	})
+}
+
+// newPoolTemplate creates the template paying the current shares,
+// and keeps its block to rebuild the shares mined from it.
+func newPoolTemplate(ctx context.Context) (*pool.Template, error) {
+	b, err := newPoolBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	poolTemplates.Add(b)
+
+	return miningPool.NewTemplate(b), nil
+}
+
+func newPoolBlock(ctx context.Context) (*block.Block, error) {
+	txs, fees, err := chainNode.FindTemplateTxs(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := miningPool.NewBlock(
+		txs, block.Reward(txs, fees), blockchain.MinerAddr,
+		blockchain.HeadHash, blockchain.Difficulty,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create block")
+	}
+	b.Header.Version = chainNode.Deployments.Version()
+
+	return b, nil
+}
+
+// submitShare accounts the share of the worker mined from a pool template.
+// When the share meets the block difficulty, the block is connected and the
+// round ends, and the share is rejected without credit if it does not connect.
+// It resolves whether the share is a block, and the block if so.
+// Otherwise it resolves the template paying the shares including this one,
+// which the worker should mine next.
+func submitShare() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			worker, err := util.DecodeHex(util.StrToBytes(args[0].String()))
+			if err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to decode hex: %v", err))
+			}
+
+			var header block.Header
+			if err := json.Unmarshal(util.FromJSObject(args[1]), &header); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to unmarshal header: %v", err))
+			}
+
+			b, err := poolTemplates.Rebuild(&header, uint32(args[2].Int()))
+			if err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			if !bytes.Equal(b.Header.PrevHash, blockchain.HeadHash) {
+				return reject.Invoke("share is stale")
+			}
+
+			isBlock, err := miningPool.Submit(worker, b.Header)
+			if err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			res := struct {
+				IsBlock  bool           `json:"isBlock"`
+				Block    *block.Block   `json:"block,omitempty"`
+				Template *pool.Template `json:"template,omitempty"`
+			}{IsBlock: isBlock}
+
+			ctx := context.Background()
+
+			if !isBlock {
+				// the split changed, so the block found next should pay by it.
+				if res.Template, err = newPoolTemplate(ctx); err != nil {
+					return reject.Invoke(err.Error())
+				}
+			}
+
+			if isBlock {
+				if err := consensus.CheckHeader(b.Header, chainNode.Difficulty(), chainNode.PowAlgorithm()); err != nil {
+					return reject.Invoke(err.Error())
+				}
+
+				if err := chainNode.CommitMinedBlock(ctx, b); err != nil {
+					return reject.Invoke(err.Error())
+				}
+				miningPool.Reset()
+
+				b.Body.CoinbaseTxHash = nil
+				b.Body.TxHashes = nil
+				res.Block = b
+			}
+
+			data, _ := json.Marshal(res)
+			return resolve.Invoke(util.ToJSObject(data))
+		}))
+	})
+}
+
+func getPoolShares() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			data, _ := json.Marshal(miningPool.Shares())
+			return resolve.Invoke(util.ToJSObject(data))
+		}))
+	})
+}
+
+// mineShare mines a pool template at its share difficulty with the local
+// miner, and resolves the nonce to submit.
+func mineShare() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			var tmpl pool.Template
+			if err := json.Unmarshal(util.FromJSObject(args[0]), &tmpl); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to unmarshal template: %v", err))
+			}
+
+			r, err := miner.Mine(miningContext(), tmpl.HashInput, tmpl.ShareDifficulty, processor.Options{})
+			if err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to mine share: %v", err))
+			}
+
+			return resolve.Invoke(r.Nonce)
+		}))
+	})
+}
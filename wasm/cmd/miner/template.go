@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	"miner/internal/block"
+	"miner/internal/blocktemplate"
+	"miner/internal/consensus"
+	"miner/internal/misc/promise"
+	"miner/internal/misc/util"
+)
+
+var templates = blocktemplate.NewStore(blocktemplate.DefaultStoreLimit)
+
+// getBlockTemplate returns the work of a block on the current head with every
+// mempool transaction, for the miners outside of this node.
+func getBlockTemplate() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			b, err := chainNode.NewBlockTemplate(context.Background(), nil)
+			if err != nil {
+				return reject.Invoke(err.Error())
+			}
+			templates.Add(b)
+
+			data, _ := json.Marshal(blocktemplate.New(b, powAlgorithm))
+			return resolve.Invoke(util.ToJSObject(data))
+		}))
+	})
+}
+
+// submitBlock completes the header mined from a template with its nonce,
+// and connects the block with the same rules as insertBroadcastedBlock.
+// It resolves the block to broadcast.
+func submitBlock() any {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
+			var header block.Header
+			if err := json.Unmarshal(util.FromJSObject(args[0]), &header); err != nil {
+				return reject.Invoke(fmt.Sprintf("failed to unmarshal header: %v", err))
+			}
+			nonce := uint32(args[1].Int())
+
+			b, err := templates.Rebuild(&header, nonce)
+			if err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			if err := consensus.CheckHeader(b.Header, chainNode.Difficulty(), chainNode.PowAlgorithm()); err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			ctx := context.Background()
+
+			if err := chainNode.CommitMinedBlock(ctx, b); err != nil {
+				return reject.Invoke(err.Error())
+			}
+
+			b.Body.CoinbaseTxHash = nil
+			b.Body.TxHashes = nil
+
+			data, _ := json.Marshal(b)
+			return resolve.Invoke(util.ToJSObject(data))
+		}))
+	})
+}
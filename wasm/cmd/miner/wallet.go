@@ -54,7 +54,7 @@ func setHeadHash() any {
 	return js.FuncOf(func(this js.Value, args []js.Value) any {
 		return promise.New(promise.NewHandler(func(resolve, reject js.Value) any {
 			b, _ := util.DecodeHex(util.StrToBytes(args[0].String()))
-			blockchain.HeadHash = b
+			chainNode.SetHead(b)
 			return resolve.Invoke()
 		}))
 	})
@@ -0,0 +1,194 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/storage"
+	"miner/internal/tx"
+)
+
+// maxBodySize limits the blocks and the transactions posted.
+const maxBodySize = 4 << 20
+
+// handler serves the HTTP API:
+//
+//	GET  /head             hash of the head
+//	GET  /blocks/{hash}    block of the hex hash, without its transactions
+//	POST /blocks           block broadcasted by a peer
+//	POST /txs              transaction broadcasted by a peer
+//	GET  /balance/{addr}   coins of the hex public key
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/head", s.getHead)
+	mux.HandleFunc("/blocks/", s.getBlock)
+	mux.HandleFunc("/blocks", s.postBlock)
+	mux.HandleFunc("/txs", s.postTx)
+	mux.HandleFunc("/balance/", s.getBalance)
+	return mux
+}
+
+func (s *server) getHead(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	s.mu.Lock()
+	head := s.node.HeadHash()
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]hash.Hash{"hash": head})
+}
+
+func (s *server) getBlock(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	blockHash, ok := hexPathParam(w, r, "/blocks/")
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	header, err := storage.FindBlockHeader(ctx, blockHash)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if header == nil {
+		writeError(w, http.StatusNotFound, nil)
+		return
+	}
+
+	// genesis has no body.
+	body := &block.Body{}
+	if !bytes.Equal(header.CurHash, blockchain.GenesisHash()) {
+		if body, err = storage.FindBlockBody(ctx, blockHash); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, &block.Block{Header: header, Body: body})
+}
+
+func (s *server) postBlock(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var b block.Block
+	if !readJSON(w, r, &b) {
+		return
+	}
+	if b.Header == nil || b.Body == nil {
+		writeError(w, http.StatusBadRequest, nil)
+		return
+	}
+
+	s.mu.Lock()
+	err := s.node.ReceiveBlock(r.Context(), &b)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) postTx(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var transaction tx.Transaction
+	if !readJSON(w, r, &transaction) {
+		return
+	}
+
+	s.mu.Lock()
+	err := s.node.AddTx(r.Context(), &transaction)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) getBalance(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	addr, ok := hexPathParam(w, r, "/balance/")
+	if !ok {
+		return
+	}
+
+	// the outputs are found from the head.
+	s.mu.Lock()
+	_, got, err := storage.FindUTxOutputs(r.Context(), addr)
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]uint64{"balance": got})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, nil)
+		return false
+	}
+	return true
+}
+
+// hexPathParam decodes the hex path segment after prefix.
+func hexPathParam(w http.ResponseWriter, r *http.Request, prefix string) (hash.Hash, bool) {
+	b, err := util.DecodeHex(util.StrToBytes(strings.TrimPrefix(r.URL.Path, prefix)))
+	if err != nil || len(b) == 0 {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return b, true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(dst); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+// writeError writes the error, or the status text if err is nil.
+func writeError(w http.ResponseWriter, status int, err error) {
+	msg := http.StatusText(status)
+	if err != nil {
+		msg = err.Error()
+	}
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,131 @@
+//go:build !(js && wasm)
+
+// Command node runs a headless full node outside the browser, so that
+// always-on seed nodes can be hosted next to the browser clients. It keeps the
+// chain in files under -data, optionally mines on the head, and serves the
+// chain over HTTP:
+//
+//	go run ./cmd/node -data ./blockchain -miner-addr <hex public key> -mine
+//
+// The browser clients talk to each other over socket.io and WebRTC from JS,
+// which the node does not speak. Blocks and transactions are exchanged with
+// it through the HTTP API instead.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"miner/internal/automine"
+	"miner/internal/blockchain"
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/node"
+	"miner/internal/processor"
+	"miner/internal/storage"
+)
+
+// server is the node and the miner on it. mu serializes the access to the
+// node, since the miner and the HTTP handlers use it concurrently.
+type server struct {
+	mu   sync.Mutex
+	node *node.Node
+
+	autoMiner *automine.Miner
+	cpu       processor.CPUMiner
+}
+
+func main() {
+	var (
+		data      = flag.String("data", storage.Dir, "directory of the chain")
+		minerAddr = flag.String("miner-addr", "", "hex public key the mined coins go to")
+		mine      = flag.Bool("mine", false, "mine blocks on the head continuously")
+		workers   = flag.Int("workers", 0, "workers of the CPU miner, GOMAXPROCS if 0")
+		dutyCycle = flag.Float64("duty-cycle", 1, "fraction of the time the CPU miner works")
+		listen    = flag.String("listen", ":8080", "address of the HTTP API")
+	)
+	flag.Parse()
+
+	addr, err := util.DecodeHex(util.StrToBytes(*minerAddr))
+	if err != nil {
+		fail(fmt.Errorf("invalid miner address: %w", err))
+	}
+	if *mine && len(addr) == 0 {
+		fail(errors.New("-mine requires -miner-addr"))
+	}
+	blockchain.MinerAddr = addr
+
+	throttle, err := processor.NewThrottle(processor.ThrottleConfig{Workers: *workers, DutyCycle: *dutyCycle})
+	if err != nil {
+		fail(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	storage.Dir = *data
+	if err := storage.InitDB(ctx); err != nil {
+		fail(err)
+	}
+
+	s := &server{
+		cpu: processor.CPUMiner{Workers: *workers, Algorithm: node.ChainAlgorithm(), Throttle: throttle},
+	}
+	s.autoMiner = automine.New(automine.Config{
+		Build:  s.buildBlock,
+		Mine:   s.mineBlock,
+		Commit: s.commitBlock,
+		OnError: func(err error) {
+			log.Print("auto-mining failed: ", err)
+		},
+	})
+	s.node = node.New(node.Config{
+		OnHeadChanged:    s.autoMiner.HeadChanged,
+		OnMempoolChanged: s.mempoolChanged,
+		RequestParent: func(parentHash hash.Hash) {
+			log.Printf("orphan block is waiting for %x", parentHash)
+		},
+		OnError: func(err error) {
+			log.Print(err)
+		},
+	})
+	if err := s.node.Load(ctx); err != nil {
+		fail(err)
+	}
+	log.Printf("loaded chain of head %x from %s", blockchain.HeadHash, storage.Dir)
+
+	if *mine {
+		if err := s.autoMiner.Start(); err != nil {
+			fail(err)
+		}
+		defer s.autoMiner.Stop()
+	}
+
+	srv := &http.Server{Addr: *listen, Handler: s.handler()}
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("listening on %s", *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "node:", err)
+	os.Exit(1)
+}
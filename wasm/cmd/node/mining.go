@@ -0,0 +1,61 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/processor"
+)
+
+// buildBlock builds the template of the auto-miner on the head.
+func (s *server) buildBlock(ctx context.Context) (*block.Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.node.NewBlockTemplate(ctx, nil)
+}
+
+// mineBlock finds the nonce of the block, and moves to the next extra-nonce
+// whenever the nonces are used up.
+func (s *server) mineBlock(ctx context.Context, b *block.Block) error {
+	for {
+		r, err := s.cpu.Mine(ctx, b.Header.MakeHashInput(), b.Header.Difficulty, processor.Options{})
+		if err == nil {
+			b.Header.Nonce = r.Nonce
+			b.Header.CurHash = b.Header.MakeHash()
+			return nil
+		}
+
+		if !errors.Is(err, processor.ErrNonceNotFound) {
+			return err
+		}
+
+		if err := b.IncrementExtraNonce(); err != nil {
+			return errors.Wrap(err, "failed to increment extra-nonce")
+		}
+	}
+}
+
+// commitBlock connects the block mined by the auto-miner.
+func (s *server) commitBlock(ctx context.Context, b *block.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.node.CommitMinedBlock(ctx, b); err != nil {
+		return err
+	}
+
+	log.Printf("mined block %x", b.Header.CurHash)
+	return nil
+}
+
+// mempoolChanged lets the auto-miner restart with a better mempool.
+// It is called by the node, so s.mu is held.
+func (s *server) mempoolChanged() {
+	s.autoMiner.MempoolChanged(s.node.MempoolValue())
+}
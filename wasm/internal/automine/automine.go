@@ -0,0 +1,206 @@
+// Package automine implements the loop which keeps mining blocks on the head
+// of the chain with the transactions of the mempool.
+package automine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+)
+
+var ErrRunning = errors.New("auto-miner is already running")
+
+const (
+	// DefaultMinImprovement is the ratio by which the mempool should beat
+	// the template being mined to restart mining.
+	DefaultMinImprovement = 0.1
+	// DefaultRetryInterval is the interval to retry after a failure.
+	DefaultRetryInterval = time.Second
+)
+
+// Config is the configuration of the auto-miner.
+type Config struct {
+	// Build builds a block template on the current head.
+	Build func(ctx context.Context) (*block.Block, error)
+	// Mine finds the nonce of the block.
+	Mine func(ctx context.Context, b *block.Block) error
+	// Commit connects the mined block to the chain and announces it.
+	Commit func(ctx context.Context, b *block.Block) error
+	// OnError is called with the failures of the loop. Optional.
+	OnError func(err error)
+
+	// MinImprovement is DefaultMinImprovement if zero.
+	MinImprovement float64
+	// RetryInterval is DefaultRetryInterval if zero.
+	RetryInterval time.Duration
+}
+
+// Value returns what the miner of the block earns.
+func Value(b *block.Block) uint64 {
+	return b.Body.CoinbaseTx.OutputSum()
+}
+
+// Miner mines blocks continuously once started. Its methods are safe for
+// concurrent use.
+type Miner struct {
+	cfg Config
+
+	mu      sync.Mutex
+	stop    context.CancelFunc
+	stopped chan struct{}
+	// restart cancels mining of the current template.
+	restart context.CancelFunc
+	value   uint64
+	wake    chan struct{}
+}
+
+func New(cfg Config) *Miner {
+	if cfg.MinImprovement == 0 {
+		cfg.MinImprovement = DefaultMinImprovement
+	}
+	if cfg.RetryInterval == 0 {
+		cfg.RetryInterval = DefaultRetryInterval
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+
+	return &Miner{cfg: cfg}
+}
+
+// Start starts the loop.
+func (m *Miner) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stop != nil {
+		return ErrRunning
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m.stop = stop
+	m.stopped = make(chan struct{})
+	m.wake = make(chan struct{}, 1)
+
+	go m.run(ctx, m.stopped)
+
+	return nil
+}
+
+// Stop stops the loop and waits until it returns.
+// The block being mined is abandoned.
+func (m *Miner) Stop() {
+	m.mu.Lock()
+	stop, stopped := m.stop, m.stopped
+	m.stop, m.stopped = nil, nil
+	m.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	stop()
+	<-stopped
+}
+
+// Running reports whether the loop is running.
+func (m *Miner) Running() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stop != nil
+}
+
+// HeadChanged restarts mining on the new head at once.
+func (m *Miner) HeadChanged() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.restartLocked()
+}
+
+// MempoolChanged restarts mining when the mempool is worth value, and
+// it beats the template being mined by MinImprovement.
+func (m *Miner) MempoolChanged(value uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if float64(value) >= float64(m.value)*(1+m.cfg.MinImprovement) && value > m.value {
+		m.restartLocked()
+	}
+}
+
+func (m *Miner) restartLocked() {
+	if m.restart != nil {
+		m.restart()
+		m.restart = nil
+	}
+
+	// wake the loop waiting to retry.
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Miner) run(ctx context.Context, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	for ctx.Err() == nil {
+		if err := m.round(ctx); err != nil && ctx.Err() == nil {
+			m.cfg.OnError(err)
+
+			select {
+			case <-ctx.Done():
+			case <-m.wake:
+			case <-time.After(m.cfg.RetryInterval):
+			}
+		}
+	}
+}
+
+// round mines a template, and commits it unless it is stale.
+func (m *Miner) round(ctx context.Context) error {
+	roundCtx, restart := context.WithCancel(ctx)
+	defer restart()
+
+	m.mu.Lock()
+	m.restart = restart
+	// restart requested before the template is built is consumed by this round.
+	select {
+	case <-m.wake:
+	default:
+	}
+	m.mu.Unlock()
+
+	b, err := m.cfg.Build(roundCtx)
+	if err != nil {
+		return errors.Wrap(err, "failed to build block template")
+	}
+
+	m.mu.Lock()
+	m.value = Value(b)
+	m.mu.Unlock()
+
+	if err := m.cfg.Mine(roundCtx, b); err != nil {
+		if roundCtx.Err() != nil {
+			// the template is stale, or the loop is stopped.
+			return nil
+		}
+		return errors.Wrap(err, "failed to mine block")
+	}
+
+	if roundCtx.Err() != nil {
+		return nil
+	}
+
+	if err := m.cfg.Commit(ctx, b); err != nil {
+		return errors.Wrap(err, "failed to commit block")
+	}
+
+	return nil
+}
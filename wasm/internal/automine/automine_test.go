@@ -0,0 +1,175 @@
+package automine_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"miner/internal/automine"
+	"miner/internal/block"
+	"miner/internal/tx"
+)
+
+// js/wasm runtime keeps a goroutine to handle the events of the timers.
+var ignoreJSEvents = goleak.IgnoreAnyFunction("runtime.handleEvent")
+
+// fakeChain builds templates worth value, and mines only when allowed.
+type fakeChain struct {
+	mu        sync.Mutex
+	value     uint64
+	builds    int
+	committed []*block.Block
+
+	mined chan struct{}
+	built chan struct{}
+}
+
+func newFakeChain() *fakeChain {
+	return &fakeChain{mined: make(chan struct{}), built: make(chan struct{}, 100)}
+}
+
+func (c *fakeChain) config() automine.Config {
+	return automine.Config{
+		Build: func(ctx context.Context) (*block.Block, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			c.builds++
+			c.built <- struct{}{}
+
+			coinbase := &tx.Transaction{Outputs: []*tx.TxOutput{{Amount: c.value}}}
+			return &block.Block{Header: &block.Header{}, Body: &block.Body{CoinbaseTx: coinbase}}, nil
+		},
+		Mine: func(ctx context.Context, b *block.Block) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-c.mined:
+				return nil
+			}
+		},
+		Commit: func(ctx context.Context, b *block.Block) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			c.committed = append(c.committed, b)
+			return nil
+		},
+		RetryInterval: time.Millisecond,
+	}
+}
+
+func (c *fakeChain) waitBuild(t *testing.T) {
+	select {
+	case <-c.built:
+	case <-time.After(time.Second):
+		t.Fatal("template is not built")
+	}
+}
+
+func (c *fakeChain) stats() (builds, committed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.builds, len(c.committed)
+}
+
+func TestMiner(t *testing.T) {
+	defer goleak.VerifyNone(t, ignoreJSEvents)
+
+	chain := newFakeChain()
+	chain.value = 10
+
+	m := automine.New(chain.config())
+	require.NoError(t, m.Start())
+	assert.ErrorIs(t, m.Start(), automine.ErrRunning)
+	assert.True(t, m.Running())
+
+	t.Run("commit mined blocks", func(t *testing.T) {
+		chain.waitBuild(t)
+		chain.mined <- struct{}{}
+		chain.waitBuild(t)
+		chain.mined <- struct{}{}
+		chain.waitBuild(t)
+
+		builds, committed := chain.stats()
+		assert.Equal(t, 3, builds)
+		assert.Equal(t, 2, committed)
+	})
+
+	t.Run("restart on new head", func(t *testing.T) {
+		m.HeadChanged()
+		chain.waitBuild(t)
+
+		builds, committed := chain.stats()
+		assert.Equal(t, 4, builds)
+		assert.Equal(t, 2, committed)
+	})
+
+	t.Run("restart on better mempool", func(t *testing.T) {
+		// not significantly better.
+		m.MempoolChanged(10)
+		m.MempoolChanged(10)
+
+		chain.mu.Lock()
+		chain.value = 20
+		chain.mu.Unlock()
+
+		m.MempoolChanged(20)
+		chain.waitBuild(t)
+
+		builds, _ := chain.stats()
+		assert.Equal(t, 5, builds)
+	})
+
+	m.Stop()
+	assert.False(t, m.Running())
+
+	// stopping again does nothing.
+	m.Stop()
+}
+
+func TestMinerRetry(t *testing.T) {
+	defer goleak.VerifyNone(t, ignoreJSEvents)
+
+	chain := newFakeChain()
+	cfg := chain.config()
+
+	var errs []error
+	var mu sync.Mutex
+	failures := 2
+
+	build := cfg.Build
+	cfg.Build = func(ctx context.Context) (*block.Block, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failures > 0 {
+			failures--
+			return nil, errors.New("mempool is not ready")
+		}
+		return build(ctx)
+	}
+	cfg.OnError = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs = append(errs, err)
+	}
+
+	m := automine.New(cfg)
+	require.NoError(t, m.Start())
+	defer m.Stop()
+
+	chain.waitBuild(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, errs, 2)
+}
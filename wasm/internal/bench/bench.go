@@ -0,0 +1,266 @@
+// Package bench measures the miners against fixed header inputs, so that the
+// backends and their tuning can be compared between releases.
+package bench
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/pow"
+	"miner/internal/processor"
+	"miner/internal/versionbits"
+)
+
+var (
+	ErrUnknownMiner  = errors.New("unknown benchmark miner")
+	ErrInvalidConfig = errors.New("benchmark config is not valid")
+)
+
+// The miners a benchmark can run.
+const (
+	MinerCPU      = "cpu"
+	MinerEmulator = "emulator"
+)
+
+// unsolvable is the difficulty no header is expected to be solved at.
+const unsolvable = 255
+
+// Config is what a benchmark runs.
+type Config struct {
+	// Miners are the names of the miners to run.
+	Miners []string `json:"miners"`
+	// Algorithm is the name of the proof-of-work algorithm.
+	Algorithm string `json:"algorithm"`
+	// Difficulties are the targets the time to solution is measured for,
+	// from 0 to 255.
+	Difficulties []int `json:"difficulties"`
+	// Samples is the number of the headers solved per difficulty.
+	Samples int `json:"samples"`
+	// HashDuration is how long the hash rate is measured for.
+	HashDuration time.Duration `json:"hashDuration"`
+	// CPUWorkers and CPUBatchSize are CPUMiner.Workers and CPUMiner.BatchSize.
+	CPUWorkers   int    `json:"cpuWorkers"`
+	CPUBatchSize uint32 `json:"cpuBatchSize"`
+	// Kernel is the dispatch geometry of the emulator.
+	Kernel processor.KernelConfig `json:"kernel"`
+}
+
+// Report is the result of a benchmark.
+type Report struct {
+	GoVersion string        `json:"goVersion"`
+	GOOS      string        `json:"goos"`
+	GOARCH    string        `json:"goarch"`
+	NumCPU    int           `json:"numCPU"`
+	StartedAt time.Time     `json:"startedAt"`
+	Config    Config        `json:"config"`
+	Miners    []MinerReport `json:"miners"`
+}
+
+// MinerReport is the result of a miner.
+type MinerReport struct {
+	Name string `json:"name"`
+	// HashRate is the number of the hashes tried per second without a solution.
+	HashRate     float64            `json:"hashRate"`
+	Difficulties []DifficultyReport `json:"difficulties"`
+}
+
+// DifficultyReport is the distribution of the time to solution of a difficulty.
+// Durations are in nanoseconds.
+type DifficultyReport struct {
+	Difficulty uint8 `json:"difficulty"`
+	Samples    int   `json:"samples"`
+
+	Mean time.Duration `json:"mean"`
+	Min  time.Duration `json:"min"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	Max  time.Duration `json:"max"`
+	// MeanHashes is the mean number of the hashes tried to a solution,
+	// which is ExpectedHashes of the difficulty for an unbiased miner.
+	MeanHashes     float64 `json:"meanHashes"`
+	ExpectedHashes float64 `json:"expectedHashes"`
+	// AllocsPerSolve and BytesPerSolve are the mean heap allocations of a solution.
+	AllocsPerSolve float64 `json:"allocsPerSolve"`
+	BytesPerSolve  float64 `json:"bytesPerSolve"`
+}
+
+// Input returns the hash input of the i-th fixed header of difficulty,
+// which is the same in every run.
+func Input(i int, difficulty uint8) []byte {
+	var seed [8]byte
+	binary.LittleEndian.PutUint64(seed[:], uint64(i))
+
+	prevHash := sha256.Sum256(append([]byte("prev"), seed[:]...))
+	dataHash := sha256.Sum256(append([]byte("data"), seed[:]...))
+
+	header := block.Header{
+		Version:    versionbits.TopBits,
+		PrevHash:   prevHash[:],
+		DataHash:   dataHash[:],
+		Timestamp:  time.Unix(1700000000, 0),
+		Difficulty: difficulty,
+	}
+	return header.MakeHashInput()
+}
+
+// Run runs the miners of cfg one after another.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	alg, err := pow.Lookup(cfg.Algorithm)
+	if err != nil {
+		return Report{}, err
+	}
+	if cfg.Samples <= 0 {
+		return Report{}, errors.Wrapf(ErrInvalidConfig, "%d samples", cfg.Samples)
+	}
+	for _, d := range cfg.Difficulties {
+		if d < 0 || d > math.MaxUint8 {
+			return Report{}, errors.Wrapf(ErrInvalidConfig, "difficulty %d", d)
+		}
+	}
+	if err := cfg.Kernel.Validate(); err != nil {
+		return Report{}, err
+	}
+	// the report records the geometry actually dispatched.
+	cfg.Kernel = cfg.Kernel.WithDefaults()
+
+	miners := make([]processor.Miner, len(cfg.Miners))
+	for i, name := range cfg.Miners {
+		if miners[i], err = newMiner(name, alg, cfg); err != nil {
+			return Report{}, err
+		}
+	}
+
+	report := Report{
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		NumCPU:    runtime.NumCPU(),
+		StartedAt: time.Now(),
+		Config:    cfg,
+	}
+
+	for i, m := range miners {
+		r, err := runMiner(ctx, cfg, m)
+		if err != nil {
+			return Report{}, errors.Wrapf(err, "failed to run %s", cfg.Miners[i])
+		}
+		r.Name = cfg.Miners[i]
+		report.Miners = append(report.Miners, r)
+	}
+
+	return report, nil
+}
+
+func newMiner(name string, alg pow.Algorithm, cfg Config) (processor.Miner, error) {
+	switch name {
+	case MinerCPU:
+		return processor.CPUMiner{Workers: cfg.CPUWorkers, BatchSize: cfg.CPUBatchSize, Algorithm: alg}, nil
+	case MinerEmulator:
+		// the kernel only implements pow.SHA256.
+		if alg != pow.SHA256 {
+			return nil, errors.Wrapf(processor.ErrBackendUnavailable, "emulator does not support %s", alg.Name())
+		}
+		return processor.KernelMiner{
+			NewDevice: func() processor.KernelDevice { return processor.NewEmulator() },
+			Config:    cfg.Kernel,
+		}, nil
+	}
+
+	return nil, errors.Wrapf(ErrUnknownMiner, "%q", name)
+}
+
+func runMiner(ctx context.Context, cfg Config, m processor.Miner) (MinerReport, error) {
+	hashRate, err := measureHashRate(ctx, m, cfg.HashDuration)
+	if err != nil {
+		return MinerReport{}, err
+	}
+
+	r := MinerReport{HashRate: hashRate}
+	for _, difficulty := range cfg.Difficulties {
+		d, err := measureSolutions(ctx, m, uint8(difficulty), cfg.Samples)
+		if err != nil {
+			return MinerReport{}, errors.Wrapf(err, "difficulty %d", difficulty)
+		}
+		r.Difficulties = append(r.Difficulties, d)
+	}
+
+	return r, nil
+}
+
+// measureHashRate mines an unsolvable header for d.
+func measureHashRate(ctx context.Context, m processor.Miner, d time.Duration) (float64, error) {
+	if d <= 0 {
+		return 0, nil
+	}
+
+	telemetry := processor.NewTelemetry(unsolvable)
+	mineCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	start := time.Now()
+	_, err := m.Mine(mineCtx, Input(0, unsolvable), unsolvable, processor.Options{Telemetry: telemetry})
+	if ctx.Err() != nil || !errors.Is(err, processor.ErrCancelled) {
+		return 0, errors.Wrap(err, "failed to measure hash rate")
+	}
+
+	return float64(telemetry.Progress().Hashes) / time.Since(start).Seconds(), nil
+}
+
+// measureSolutions solves samples fixed headers of difficulty.
+func measureSolutions(ctx context.Context, m processor.Miner, difficulty uint8, samples int) (DifficultyReport, error) {
+	durations := make([]time.Duration, samples)
+	var (
+		total  time.Duration
+		hashes uint64
+		before runtime.MemStats
+		after  runtime.MemStats
+	)
+
+	runtime.ReadMemStats(&before)
+	for i := range durations {
+		telemetry := processor.NewTelemetry(difficulty)
+
+		start := time.Now()
+		if _, err := m.Mine(ctx, Input(i, difficulty), difficulty, processor.Options{Telemetry: telemetry}); err != nil {
+			return DifficultyReport{}, err
+		}
+		durations[i] = time.Since(start)
+
+		total += durations[i]
+		hashes += telemetry.Progress().Hashes
+	}
+	runtime.ReadMemStats(&after)
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	return DifficultyReport{
+		Difficulty:     difficulty,
+		Samples:        samples,
+		Mean:           total / time.Duration(samples),
+		Min:            durations[0],
+		P50:            percentile(durations, 50),
+		P90:            percentile(durations, 90),
+		Max:            durations[samples-1],
+		MeanHashes:     float64(hashes) / float64(samples),
+		ExpectedHashes: processor.ExpectedHashes(difficulty),
+		AllocsPerSolve: float64(after.Mallocs-before.Mallocs) / float64(samples),
+		BytesPerSolve:  float64(after.TotalAlloc-before.TotalAlloc) / float64(samples),
+	}, nil
+}
+
+// percentile returns the nearest-rank percentile p of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,82 @@
+package bench_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"miner/internal/bench"
+	"miner/internal/pow"
+	"miner/internal/processor"
+)
+
+// js/wasm runtime keeps a goroutine to handle the events of the timers.
+var ignoreJSEvents = goleak.IgnoreAnyFunction("runtime.handleEvent")
+
+func TestRun(t *testing.T) {
+	defer goleak.VerifyNone(t, ignoreJSEvents)
+
+	cfg := bench.Config{
+		Miners:       []string{bench.MinerCPU, bench.MinerEmulator},
+		Algorithm:    pow.NameSHA256,
+		Difficulties: []int{4, 8},
+		Samples:      5,
+		HashDuration: 50 * time.Millisecond,
+		Kernel:       processor.KernelConfig{Workgroups: 1, BatchSize: 16},
+	}
+
+	report, err := bench.Run(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Len(t, report.Miners, 2)
+
+	for i, m := range report.Miners {
+		assert.Equal(t, cfg.Miners[i], m.Name)
+		assert.Positive(t, m.HashRate)
+		require.Len(t, m.Difficulties, 2)
+
+		for j, d := range m.Difficulties {
+			assert.Equal(t, uint8(cfg.Difficulties[j]), d.Difficulty)
+			assert.Equal(t, cfg.Samples, d.Samples)
+			assert.LessOrEqual(t, d.Min, d.P50)
+			assert.LessOrEqual(t, d.P50, d.P90)
+			assert.LessOrEqual(t, d.P90, d.Max)
+			assert.Positive(t, d.MeanHashes)
+			assert.Equal(t, processor.ExpectedHashes(d.Difficulty), d.ExpectedHashes)
+		}
+	}
+
+	b, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"hashRate"`)
+}
+
+func TestRunInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := bench.Run(ctx, bench.Config{Miners: []string{"fpga"}, Algorithm: pow.NameSHA256, Samples: 1})
+	assert.ErrorIs(t, err, bench.ErrUnknownMiner)
+
+	_, err = bench.Run(ctx, bench.Config{Algorithm: "x11", Samples: 1})
+	assert.ErrorIs(t, err, pow.ErrUnknownAlgorithm)
+
+	_, err = bench.Run(ctx, bench.Config{Algorithm: pow.NameSHA256})
+	assert.ErrorIs(t, err, bench.ErrInvalidConfig)
+
+	_, err = bench.Run(ctx, bench.Config{Algorithm: pow.NameSHA256, Samples: 1, Difficulties: []int{256}})
+	assert.ErrorIs(t, err, bench.ErrInvalidConfig)
+
+	// the kernel only implements sha256.
+	_, err = bench.Run(ctx, bench.Config{Miners: []string{bench.MinerEmulator}, Algorithm: pow.NameScrypt, Samples: 1})
+	assert.ErrorIs(t, err, processor.ErrBackendUnavailable)
+}
+
+func TestInput(t *testing.T) {
+	assert.Equal(t, bench.Input(3, 12), bench.Input(3, 12))
+	assert.NotEqual(t, bench.Input(3, 12), bench.Input(4, 12))
+	assert.NotEqual(t, bench.Input(3, 12), bench.Input(3, 16))
+}
@@ -11,11 +11,16 @@ import (
 
 	"miner/internal/blockchain"
 	"miner/internal/hash"
+	"miner/internal/misc/wire"
+	"miner/internal/pow"
 	"miner/internal/tx"
+	"miner/internal/versionbits"
 )
 
 // Header is header part of the block.
 type Header struct {
+	// Version signals deployments of rule changes, see package versionbits.
+	Version  uint32    `json:"version"`
 	CurHash  hash.Hash `json:"curHash"`
 	PrevHash hash.Hash `json:"prevHash"`
 
@@ -39,19 +44,31 @@ type Block struct {
 	Body   *Body   `json:"body"`
 }
 
-// New creates new block from given arguments.
+// New creates new block from given arguments. fees is the sum of
+// the fees txs pay, which goes to minerAddr with the prize.
 // You still have to configure [nonce, hash].
-func New(minerAddr []byte, txs []*tx.Transaction, prevHash []byte, difficulty uint8) (*Block, error) {
+func New(minerAddr []byte, txs []*tx.Transaction, fees uint64, prevHash []byte, difficulty uint8) (*Block, error) {
+	return NewWithCoinbase([]*tx.TxOutput{{
+		Addr:   minerAddr,
+		Amount: Reward(txs, fees),
+	}}, txs, prevHash, difficulty)
+}
+
+// Reward returns the reward of the block with txs paying fees.
+func Reward(txs []*tx.Transaction, fees uint64) uint64 {
+	return blockchain.MiningPrize*uint64(len(txs)) + fees
+}
+
+// NewWithCoinbase creates new block whose coinbase has the outputs.
+// The outputs should sum up to Reward of txs and their fees.
+func NewWithCoinbase(outputs []*tx.TxOutput, txs []*tx.Transaction, prevHash []byte, difficulty uint8) (*Block, error) {
 	coinBaseTx := &tx.Transaction{
 		CreatedAt: time.Now(),
 		Inputs: []*tx.TxInput{{
 			TxHash: tx.COINBASE,
 			OutIdx: 0,
 		}},
-		Outputs: []*tx.TxOutput{{
-			Addr:   minerAddr,
-			Amount: blockchain.MiningPrize * uint64(len(txs)),
-		}},
+		Outputs: outputs,
 	}
 
 	h, err := coinBaseTx.MakeHash()
@@ -61,7 +78,7 @@ func New(minerAddr []byte, txs []*tx.Transaction, prevHash []byte, difficulty ui
 	coinBaseTx.Hash = h
 
 	block := &Block{
-		Header: &Header{PrevHash: prevHash, Difficulty: difficulty},
+		Header: &Header{Version: versionbits.TopBits, PrevHash: prevHash, Difficulty: difficulty},
 		Body: &Body{
 			CoinbaseTxHash: coinBaseTx.Hash,
 			CoinbaseTx:     coinBaseTx,
@@ -86,24 +103,61 @@ func New(minerAddr []byte, txs []*tx.Transaction, prevHash []byte, difficulty ui
 	return block, nil
 }
 
+// NonceSize is the size of the encoded nonce which follows the hash input.
+const NonceSize = 4
+
+// MakeHash returns SHA-256 of the canonical encoding of the header.
 func (h *Header) MakeHash() []byte {
-	hash := sha256.New()
+	return HashWithNonce(h.MakeHashInput(), h.Nonce)
+}
+
+// PowHash returns the proof-of-work hash of the header by alg, which should
+// meet the difficulty. It is the same as MakeHash for pow.SHA256.
+func (h *Header) PowHash(alg pow.Algorithm) []byte {
+	return alg.Hash(h.Encode())
+}
 
-	hash.Write(h.MakeHashInput())
-	binary.Write(hash, binary.LittleEndian, h.Nonce)
+// HashWithNonce returns the hash of the header whose hash input is in and
+// nonce is nonce. Every miner should use it, so that the nonce found by the
+// miner reproduces the hash through MakeHash.
+func HashWithNonce(in []byte, nonce uint32) []byte {
+	sum := sha256.Sum256(AppendNonce(in[:len(in):len(in)], nonce))
+	return sum[:]
+}
 
-	return hash.Sum(nil)
+// AppendNonce appends the encoded nonce to the hash input.
+func AppendNonce(in []byte, nonce uint32) []byte {
+	return binary.LittleEndian.AppendUint32(in, nonce)
 }
 
+// MakeHashInput returns the canonical encoding of the header without the nonce.
 func (h *Header) MakeHashInput() []byte {
-	buf := bytes.NewBuffer(nil)
+	var w wire.Writer
+	h.encodeHashInput(&w)
+	return w.Result()
+}
+
+// IncrementExtraNonce bumps the extra-nonce of the coinbase transaction and
+// updates the data hash, so that the header can be mined again with fresh work
+// once its nonce space is used up. The extra-nonce is the coinbase's Nonce.
+func (b *Block) IncrementExtraNonce() error {
+	coinbaseTx := b.Body.CoinbaseTx
+	coinbaseTx.Nonce++
 
-	buf.Write(h.PrevHash)
-	buf.Write(h.DataHash)
-	binary.Write(buf, binary.LittleEndian, h.Timestamp.UnixNano())
-	binary.Write(buf, binary.LittleEndian, h.Difficulty)
+	h, err := coinbaseTx.MakeHash()
+	if err != nil {
+		return errors.Wrap(err, "failed to create hash of coinbaseTx")
+	}
+	coinbaseTx.Hash = h
+	b.Body.CoinbaseTxHash = h
+
+	tree, err := b.CreateMerkleTree()
+	if err != nil {
+		return errors.Wrap(err, "merkle tree creation failed")
+	}
+	b.Header.DataHash = tree.MerkleRoot()
 
-	return buf.Bytes()
+	return nil
 }
 
 // CreateMerkleTree creates merkle tree from block's transactions.
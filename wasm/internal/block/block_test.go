@@ -88,7 +88,7 @@ func TestNew(t *testing.T) {
 		},
 	}
 
-	b, err := block.New(myAddr, txs, prevHash, 0)
+	b, err := block.New(myAddr, txs, 0, prevHash, 0)
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -96,3 +96,24 @@ func TestNew(t *testing.T) {
 	valid := b.ValidateDataHash()
 	assert.True(t, valid)
 }
+
+func TestIncrementExtraNonce(t *testing.T) {
+	txs := []*tx.Transaction{{Hash: []byte("asdfs")}}
+
+	b, err := block.New([]byte("aefafe"), txs, 0, []byte("dfskfjdshf"), 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	dataHash := b.Header.DataHash
+	coinbaseHash := b.Body.CoinbaseTxHash
+
+	assert.NoError(t, b.IncrementExtraNonce())
+
+	assert.Equal(t, uint64(1), b.Body.CoinbaseTx.Nonce)
+	assert.NotEqual(t, coinbaseHash, b.Body.CoinbaseTxHash)
+	assert.Equal(t, b.Body.CoinbaseTx.Hash, b.Body.CoinbaseTxHash)
+	assert.NotEqual(t, dataHash, b.Header.DataHash)
+	assert.True(t, b.ValidateDataHash())
+	assert.True(t, b.Body.CoinbaseTx.ValidateHash())
+}
@@ -0,0 +1,135 @@
+package block
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/misc/wire"
+	"miner/internal/tx"
+)
+
+// HeaderEncodingVersion is the version of the block header encoding.
+const HeaderEncodingVersion = 2
+
+// Encode returns the canonical encoding of the header.
+//
+//	version     uint8, HeaderEncodingVersion
+//	blockVer    uint32, Version of the header
+//	prevHash    bytes
+//	dataHash    bytes
+//	timestamp   int64, unix time in nanoseconds
+//	difficulty  uint8
+//	nonce       uint32, see AppendNonce
+//
+// The nonce comes last so that miners can hash MakeHashInput with each nonce
+// appended. The hash is not encoded since it is derived from the encoding.
+func (h *Header) Encode() []byte {
+	return AppendNonce(h.MakeHashInput(), h.Nonce)
+}
+
+func (h *Header) encodeHashInput(w *wire.Writer) {
+	w.Uint8(HeaderEncodingVersion)
+	w.Uint32(h.Version)
+	w.Bytes(h.PrevHash)
+	w.Bytes(h.DataHash)
+	w.Int64(h.Timestamp.UnixNano())
+	w.Uint8(h.Difficulty)
+}
+
+// DecodeHeader decodes the canonical encoding of a header and fills its hash.
+func DecodeHeader(b []byte) (*Header, error) {
+	r := wire.NewReader(b)
+
+	h, err := decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Finish(); err != nil {
+		return nil, errors.Wrap(err, "failed to decode header")
+	}
+
+	return h, nil
+}
+
+func decodeHeader(r *wire.Reader) (*Header, error) {
+	if v := r.Uint8(); r.Err() == nil && v != HeaderEncodingVersion {
+		return nil, errors.Errorf("unknown header encoding version: %d", v)
+	}
+
+	h := &Header{
+		Version:    r.Uint32(),
+		PrevHash:   r.Bytes(),
+		DataHash:   r.Bytes(),
+		Timestamp:  time.Unix(0, r.Int64()),
+		Difficulty: r.Uint8(),
+		Nonce:      r.Uint32(),
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to decode header")
+	}
+
+	h.CurHash = h.MakeHash()
+
+	return h, nil
+}
+
+// Encode returns the canonical encoding of the block.
+// The block should have its coinbase transaction.
+//
+//	header      header encoding
+//	coinbaseTx  transaction encoding
+//	txs         count, and transaction encoding of each
+func (b *Block) Encode() []byte {
+	var w wire.Writer
+
+	w.Raw(b.Header.Encode())
+	w.Raw(b.Body.CoinbaseTx.Encode())
+
+	w.Count(len(b.Body.Txs))
+	for _, tx := range b.Body.Txs {
+		w.Raw(tx.Encode())
+	}
+
+	return w.Result()
+}
+
+// Decode decodes the canonical encoding of a block.
+func Decode(data []byte) (*Block, error) {
+	r := wire.NewReader(data)
+
+	header, err := decodeHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	coinbaseTx, err := tx.DecodeFrom(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode coinbase transaction")
+	}
+
+	body := &Body{
+		CoinbaseTxHash: coinbaseTx.Hash,
+		CoinbaseTx:     coinbaseTx,
+	}
+
+	// transaction takes at least 19 bytes: version, createdAt, nonce and two counts.
+	n := r.Count(19)
+	for i := 0; i < n; i++ {
+		transaction, err := tx.DecodeFrom(r)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode transaction %d", i)
+		}
+
+		body.Txs = append(body.Txs, transaction)
+		body.TxHashes = append(body.TxHashes, transaction.Hash)
+	}
+
+	if err := r.Finish(); err != nil {
+		return nil, errors.Wrap(err, "failed to decode block")
+	}
+
+	return &Block{Header: header, Body: body}, nil
+}
@@ -0,0 +1,68 @@
+package block_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/tx"
+)
+
+// test vector of the canonical header encoding.
+const (
+	headerEncodingHex = "02" + // encoding version
+		"01000020" + // block version
+		"0100" + // prevHash
+		"021122" + // dataHash
+		"00002a36fe9c9717" + // timestamp
+		"16" + // difficulty
+		"04030201" // nonce
+	headerHashHex = "0df747ee8a3adfc5d48c4c523cda9382f5e538a204d9f4ff531fee47c80898e5"
+)
+
+func TestHeaderEncode(t *testing.T) {
+	header := &block.Header{
+		Version:    0x20000001,
+		PrevHash:   []byte{0x00},
+		DataHash:   []byte{0x11, 0x22},
+		Timestamp:  time.Unix(0, 1700000000000000000),
+		Difficulty: 22,
+		Nonce:      0x01020304,
+	}
+
+	assert.Equal(t, headerEncodingHex, hex.EncodeToString(header.Encode()))
+	assert.Equal(t, headerHashHex, hex.EncodeToString(header.MakeHash()))
+
+	// hash input is the encoding without the nonce.
+	assert.Equal(t, headerEncodingHex[:len(headerEncodingHex)-8], hex.EncodeToString(header.MakeHashInput()))
+
+	b, _ := hex.DecodeString(headerEncodingHex)
+	decoded, err := block.DecodeHeader(b)
+	require.NoError(t, err)
+	assert.Equal(t, headerHashHex, hex.EncodeToString(decoded.CurHash))
+}
+
+func TestBlockEncode(t *testing.T) {
+	txs := []*tx.Transaction{{
+		CreatedAt: time.Unix(0, 1700000000000000000),
+		Inputs:    []*tx.TxInput{{TxHash: []byte("prev"), OutIdx: 1, Signature: []byte("sig")}},
+		Outputs:   []*tx.TxOutput{{Addr: []byte("addr"), Amount: 20}},
+	}}
+	txs[0].Hash, _ = txs[0].MakeHash()
+
+	b, err := block.New([]byte("miner"), txs, 0, []byte("prev"), 0)
+	require.NoError(t, err)
+	b.Header.CurHash = b.Header.MakeHash()
+
+	decoded, err := block.Decode(b.Encode())
+	require.NoError(t, err)
+
+	assert.Equal(t, b.Header.CurHash, decoded.Header.CurHash)
+	assert.Equal(t, b.Body.CoinbaseTxHash, decoded.Body.CoinbaseTxHash)
+	assert.Equal(t, b.Body.TxHashes, decoded.Body.TxHashes)
+	assert.True(t, decoded.ValidateDataHash())
+}
@@ -0,0 +1,42 @@
+package block
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	stdhash "hash"
+)
+
+// NonceHasher hashes a header with many nonces. SHA-256 state of the hash
+// input(the midstate) is computed once, so each nonce costs only the final
+// blocks of the hash, and no allocation.
+//
+// It is not safe for concurrent use.
+type NonceHasher struct {
+	midstate []byte
+	digest   stdhash.Hash
+	nonce    [NonceSize]byte
+	sum      [sha256.Size]byte
+}
+
+// NewNonceHasher creates the hasher of the header whose hash input is in.
+func NewNonceHasher(in []byte) *NonceHasher {
+	digest := sha256.New()
+	digest.Write(in)
+
+	// sha256 digest always supports marshaling its state.
+	midstate, _ := digest.(encoding.BinaryMarshaler).MarshalBinary()
+
+	return &NonceHasher{midstate: midstate, digest: digest}
+}
+
+// Hash returns the same hash as HashWithNonce of the hash input and nonce.
+// The result is overwritten by the next call.
+func (h *NonceHasher) Hash(nonce uint32) []byte {
+	_ = h.digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(h.midstate)
+
+	binary.LittleEndian.PutUint32(h.nonce[:], nonce)
+	h.digest.Write(h.nonce[:])
+
+	return h.digest.Sum(h.sum[:0])
+}
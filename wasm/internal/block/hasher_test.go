@@ -0,0 +1,72 @@
+package block_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"miner/internal/block"
+	"miner/internal/versionbits"
+)
+
+func newTestHeader() *block.Header {
+	return &block.Header{
+		Version:    versionbits.TopBits,
+		PrevHash:   make([]byte, 32),
+		DataHash:   make([]byte, 32),
+		Timestamp:  time.Unix(0, 1700000000000000000),
+		Difficulty: 22,
+	}
+}
+
+func TestNonceHasher(t *testing.T) {
+	header := newTestHeader()
+	in := header.MakeHashInput()
+	hasher := block.NewNonceHasher(in)
+
+	for _, nonce := range []uint32{0, 1, 0xdeadbeef, 0xffffffff} {
+		header.Nonce = nonce
+		assert.Equal(t, header.MakeHash(), hasher.Hash(nonce))
+		assert.Equal(t, block.HashWithNonce(in, nonce), hasher.Hash(nonce))
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { hasher.Hash(7) })
+	assert.Zero(t, allocs)
+}
+
+// BenchmarkStreamHash is how the CPU miner hashed before the midstate:
+// a new digest fed with the hash input and the nonce through binary.Write.
+func BenchmarkStreamHash(b *testing.B) {
+	in := newTestHeader().MakeHashInput()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		digest := sha256.New()
+		digest.Write(in)
+		_ = binary.Write(digest, binary.LittleEndian, uint32(i))
+		digest.Sum(nil)
+	}
+}
+
+// BenchmarkHashWithNonce hashes the whole hash input for each nonce.
+func BenchmarkHashWithNonce(b *testing.B) {
+	in := newTestHeader().MakeHashInput()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		block.HashWithNonce(in, uint32(i))
+	}
+}
+
+// BenchmarkNonceHasher hashes only the final block from the midstate.
+func BenchmarkNonceHasher(b *testing.B) {
+	hasher := block.NewNonceHasher(newTestHeader().MakeHashInput())
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		hasher.Hash(uint32(i))
+	}
+}
@@ -2,7 +2,10 @@ package blockchain
 
 import (
 	"crypto/ecdsa"
+	"miner/internal/issuance"
 	"miner/internal/key"
+	"miner/internal/versionbits"
+	"time"
 )
 
 var adminPublicKeyHex = []byte("0495521500a56f6b7c8564d7d3b0fd724ca9bc710c8c2557a0661a94bef0d8a4248141dbc249d7be36803b0fa8b98810c48c18d394bb0aef2fe323834b86111a41")
@@ -10,6 +13,8 @@ var adminPublicKeyHex = []byte("0495521500a56f6b7c8564d7d3b0fd724ca9bc710c8c2557
 const (
 	MiningPrize = 10
 	Difficulty  = 22
+	// PowAlgorithm is the name of the proof-of-work algorithm of the chain.
+	PowAlgorithm = "sha256"
 )
 
 var (
@@ -17,6 +22,31 @@ var (
 	MinerAddr []byte
 )
 
+// MaxBlockSize limits the encoded size of the transactions of a block, except
+// the coinbase, so that miners choose the transactions paying the most.
+var MaxBlockSize = 64 * 1024
+
+// MintPolicy limits the coins the admin can issue.
+var MintPolicy = issuance.Policy{
+	Period:    24 * time.Hour,
+	PeriodCap: 100000,
+}
+
+// DeploymentFutureTimestamp rejects blocks whose timestamp is more than
+// MaxFutureBlockTime ahead of the validating node's clock.
+const DeploymentFutureTimestamp = "future-timestamp"
+
+const MaxFutureBlockTime = 2 * time.Hour
+
+// Deployments are the rule changes miners can signal for.
+var Deployments = versionbits.Params{
+	Window:    100,
+	Threshold: 75,
+	Deployments: []versionbits.Deployment{
+		{Name: DeploymentFutureTimestamp, Bit: 0},
+	},
+}
+
 func GenesisHash() []byte {
 	return []byte{0x00}
 }
@@ -25,7 +55,3 @@ func AdminPublicKey() *ecdsa.PublicKey {
 	key, _ := key.ParseECDSAPublicKey(adminPublicKeyHex)
 	return key
 }
-
-func AdminHash() []byte {
-	return []byte("admin babe")
-}
@@ -0,0 +1,123 @@
+// Package blocktemplate gives the work of blocks to miners outside of this
+// node, and rebuilds the blocks they submit.
+package blocktemplate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/hash"
+	"miner/internal/pow"
+	"miner/internal/tx"
+)
+
+var ErrUnknownTemplate = errors.New("block template is unknown or expired")
+
+// DefaultStoreLimit is the number of the templates kept by default.
+const DefaultStoreLimit = 16
+
+// Template is the work of a block.
+//
+// The proof-of-work hash of the header is the hash by Algorithm of HashInput
+// followed by the nonce as a little endian uint32, and it is valid when it is
+// not greater than Target.
+type Template struct {
+	Version    uint32            `json:"version"`
+	PrevHash   hash.Hash         `json:"prevHash"`
+	DataHash   hash.Hash         `json:"dataHash"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Difficulty uint8             `json:"difficulty"`
+	Target     hash.Hash         `json:"target"`
+	Algorithm  string            `json:"algorithm"`
+	HashInput  hash.Hash         `json:"hashInput"`
+	CoinbaseTx *tx.Transaction   `json:"coinbaseTx"`
+	Txs        []*tx.Transaction `json:"txs"`
+}
+
+// New creates the template of the block mined by alg.
+func New(b *block.Block, alg pow.Algorithm) *Template {
+	return &Template{
+		Version:    b.Header.Version,
+		PrevHash:   b.Header.PrevHash,
+		DataHash:   b.Header.DataHash,
+		Timestamp:  b.Header.Timestamp,
+		Difficulty: b.Header.Difficulty,
+		Target:     Target(b.Header.Difficulty),
+		Algorithm:  alg.Name(),
+		HashInput:  b.Header.MakeHashInput(),
+		CoinbaseTx: b.Body.CoinbaseTx,
+		Txs:        b.Body.Txs,
+	}
+}
+
+// Target returns the greatest 256-bit big endian hash
+// which has difficulty leading zero bits.
+func Target(difficulty uint8) hash.Hash {
+	target := make(hash.Hash, 32)
+	for i := range target {
+		switch bits := int(difficulty) - i*8; {
+		case bits >= 8:
+			target[i] = 0x00
+		case bits <= 0:
+			target[i] = 0xff
+		default:
+			target[i] = 0xff >> bits
+		}
+	}
+	return target
+}
+
+// Store keeps the blocks of the recent templates, so that the submitted
+// header can be completed with its body. It is safe for concurrent use.
+type Store struct {
+	mu     sync.Mutex
+	limit  int
+	order  []string
+	blocks map[string]*block.Block
+}
+
+func NewStore(limit int) *Store {
+	return &Store{limit: limit, blocks: make(map[string]*block.Block)}
+}
+
+// Add keeps the block of a template, and forgets the oldest one over the limit.
+func (s *Store) Add(b *block.Block) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := string(b.Header.DataHash)
+	if _, ok := s.blocks[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.blocks[key] = b
+
+	for len(s.order) > s.limit {
+		delete(s.blocks, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// Rebuild completes the submitted header with the body of its template found
+// by the data hash. The header keeps the fields given by the miner, so the
+// block should be validated before it is used.
+func (s *Store) Rebuild(header *block.Header, nonce uint32) (*block.Block, error) {
+	s.mu.Lock()
+	tmpl, ok := s.blocks[string(header.DataHash)]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, ErrUnknownTemplate
+	}
+
+	h := *header
+	h.Nonce = nonce
+	h.CurHash = h.MakeHash()
+
+	body := *tmpl.Body
+	body.Txs = append([]*tx.Transaction{}, tmpl.Body.Txs...)
+
+	return &block.Block{Header: &h, Body: &body}, nil
+}
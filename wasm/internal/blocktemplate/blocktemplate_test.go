@@ -0,0 +1,94 @@
+package blocktemplate_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/blocktemplate"
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/pow"
+	"miner/internal/tx"
+)
+
+func TestTarget(t *testing.T) {
+	assert.Equal(t, hash.Hash(bytes.Repeat([]byte{0xff}, 32)), blocktemplate.Target(0))
+
+	target := blocktemplate.Target(12)
+	assert.Equal(t, []byte{0x00, 0x0f, 0xff}, []byte(target[:3]))
+
+	// a hash meets the difficulty if and only if it is not greater than the target.
+	for i := uint32(0); i < 5000; i++ {
+		sum := sha256.Sum256(binary.LittleEndian.AppendUint32(nil, i))
+		assert.Equal(t, util.CheckPrefix(sum[:], 12), bytes.Compare(sum[:], target) <= 0)
+	}
+}
+
+func TestStore(t *testing.T) {
+	txs := []*tx.Transaction{{Hash: []byte("tx")}}
+
+	b, err := block.New([]byte("miner"), txs, 0, []byte("prev"), 4)
+	require.NoError(t, err)
+
+	store := blocktemplate.NewStore(2)
+	store.Add(b)
+
+	tmpl := blocktemplate.New(b, pow.SHA256)
+
+	// external miner works on the template only.
+	var nonce uint32
+	for {
+		sum := sha256.Sum256(binary.LittleEndian.AppendUint32(append([]byte{}, tmpl.HashInput...), nonce))
+		if bytes.Compare(sum[:], tmpl.Target) <= 0 {
+			break
+		}
+		nonce++
+	}
+
+	header := &block.Header{
+		Version:    tmpl.Version,
+		PrevHash:   tmpl.PrevHash,
+		DataHash:   tmpl.DataHash,
+		Timestamp:  tmpl.Timestamp,
+		Difficulty: tmpl.Difficulty,
+	}
+
+	t.Run("rebuild", func(t *testing.T) {
+		rebuilt, err := store.Rebuild(header, nonce)
+		require.NoError(t, err)
+
+		assert.Equal(t, nonce, rebuilt.Header.Nonce)
+		assert.True(t, util.CheckPrefix(rebuilt.Header.CurHash, rebuilt.Header.Difficulty))
+		assert.True(t, rebuilt.ValidateDataHash())
+		assert.Equal(t, b.Body.Txs, rebuilt.Body.Txs)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		unknown := *header
+		unknown.DataHash = []byte("unknown")
+
+		_, err := store.Rebuild(&unknown, nonce)
+		assert.ErrorIs(t, err, blocktemplate.ErrUnknownTemplate)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			other, err := block.New([]byte("miner"), nil, 0, []byte{byte(i)}, 4)
+			require.NoError(t, err)
+			// extra-nonces make the data hashes differ.
+			for j := 0; j <= i; j++ {
+				require.NoError(t, other.IncrementExtraNonce())
+			}
+			store.Add(other)
+		}
+
+		_, err := store.Rebuild(header, nonce)
+		assert.ErrorIs(t, err, blocktemplate.ErrUnknownTemplate)
+	})
+}
@@ -0,0 +1,86 @@
+// Package checkpoint keeps the progress of mining a block, so that the search
+// can resume after the page reloads instead of starting over.
+package checkpoint
+
+import (
+	"bytes"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/hash"
+)
+
+var (
+	ErrStaleTemplate     = errors.New("checkpoint template is not on the current head")
+	ErrDifficultyChanged = errors.New("checkpoint template has another difficulty")
+	ErrInvalidTemplate   = errors.New("checkpoint template is not valid")
+)
+
+// Checkpoint is the block being mined and the nonces searched for it.
+// The block is the template with its current extra-nonce.
+type Checkpoint struct {
+	Block *block.Block `json:"block"`
+	// NextNonce is the nonce to resume from. Every lower nonce of the
+	// header is searched already.
+	NextNonce uint64    `json:"nextNonce"`
+	SavedAt   time.Time `json:"savedAt"`
+}
+
+// New creates the checkpoint of b with the nonces below next searched.
+// The header and the coinbase are copied, so that b can be mined further,
+// including its extra-nonce, meanwhile.
+func New(b *block.Block, next uint64) *Checkpoint {
+	header := *b.Header
+	body := *b.Body
+	if body.CoinbaseTx != nil {
+		coinbase := *body.CoinbaseTx
+		body.CoinbaseTx = &coinbase
+	}
+
+	return &Checkpoint{
+		Block:     &block.Block{Header: &header, Body: &body},
+		NextNonce: next,
+		SavedAt:   time.Now(),
+	}
+}
+
+// Check checks whether the search can resume on head with difficulty.
+func (c *Checkpoint) Check(head hash.Hash, difficulty uint8) error {
+	b := c.Block
+	if b == nil || b.Header == nil || b.Body == nil || b.Body.CoinbaseTx == nil {
+		return ErrInvalidTemplate
+	}
+
+	if !bytes.Equal(b.Header.PrevHash, head) {
+		return ErrStaleTemplate
+	}
+
+	if b.Header.Difficulty != difficulty {
+		return ErrDifficultyChanged
+	}
+
+	if !b.ValidateDataHash() {
+		return errors.Wrap(ErrInvalidTemplate, "data hash does not match the transactions")
+	}
+
+	return nil
+}
+
+// Resume returns the block to mine and the nonce to start from. When every
+// nonce of the header is searched, the extra-nonce is bumped to start over
+// with fresh work.
+func (c *Checkpoint) Resume() (*block.Block, uint32, error) {
+	if c.NextNonce <= math.MaxUint32 {
+		return c.Block, uint32(c.NextNonce), nil
+	}
+
+	if err := c.Block.IncrementExtraNonce(); err != nil {
+		return nil, 0, errors.Wrap(err, "failed to increment extra-nonce")
+	}
+	c.NextNonce = 0
+
+	return c.Block, 0, nil
+}
@@ -0,0 +1,77 @@
+package checkpoint_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/checkpoint"
+	"miner/internal/tx"
+)
+
+func newBlock(t *testing.T) *block.Block {
+	txs := []*tx.Transaction{{Hash: []byte("asdfs")}}
+
+	b, err := block.New([]byte("miner"), txs, 0, []byte("head"), blockchain.Difficulty)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestCheckpoint(t *testing.T) {
+	b := newBlock(t)
+	require.NoError(t, b.IncrementExtraNonce())
+
+	cp := checkpoint.New(b, 5000)
+
+	// the block can be mined further without changing the checkpoint.
+	b.Header.Nonce = 42
+	dataHash := b.Header.DataHash
+	require.NoError(t, b.IncrementExtraNonce())
+
+	data, err := json.Marshal(cp)
+	require.NoError(t, err)
+
+	var loaded checkpoint.Checkpoint
+	require.NoError(t, json.Unmarshal(data, &loaded))
+
+	assert.NoError(t, loaded.Check([]byte("head"), blockchain.Difficulty))
+	assert.Equal(t, uint32(0), loaded.Block.Header.Nonce)
+	assert.Equal(t, uint64(1), loaded.Block.Body.CoinbaseTx.Nonce)
+
+	resumed, start, err := loaded.Resume()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5000), start)
+	assert.Equal(t, dataHash, resumed.Header.DataHash)
+}
+
+func TestCheckpointCheck(t *testing.T) {
+	cp := checkpoint.New(newBlock(t), 0)
+
+	assert.ErrorIs(t, cp.Check([]byte("other"), blockchain.Difficulty), checkpoint.ErrStaleTemplate)
+	assert.ErrorIs(t, cp.Check([]byte("head"), blockchain.Difficulty+1), checkpoint.ErrDifficultyChanged)
+
+	cp.Block.Body.Txs = nil
+	assert.ErrorIs(t, cp.Check([]byte("head"), blockchain.Difficulty), checkpoint.ErrInvalidTemplate)
+
+	assert.ErrorIs(t, (&checkpoint.Checkpoint{}).Check([]byte("head"), blockchain.Difficulty), checkpoint.ErrInvalidTemplate)
+}
+
+func TestCheckpointResumeUsedUp(t *testing.T) {
+	b := newBlock(t)
+	dataHash := b.Header.DataHash
+
+	cp := checkpoint.New(b, math.MaxUint32+1)
+
+	resumed, start, err := cp.Resume()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint32(0), start)
+	assert.NotEqual(t, dataHash, resumed.Header.DataHash)
+	assert.True(t, resumed.ValidateDataHash())
+}
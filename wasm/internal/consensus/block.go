@@ -0,0 +1,244 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/pow"
+	"miner/internal/tx"
+	"miner/internal/versionbits"
+)
+
+// CheckHeader validates the version and the proof of work of the header
+// against difficulty by alg. It does not depend on the rest of the chain state.
+func CheckHeader(header *block.Header, difficulty uint8, alg pow.Algorithm) error {
+	if header.Version&versionbits.TopMask != versionbits.TopBits {
+		return ErrInvalidVersion
+	}
+
+	if header.Difficulty != difficulty {
+		return ErrDifficultyMismatch
+	}
+
+	if !bytes.Equal(header.CurHash, header.MakeHash()) {
+		return ErrInvalidBlockHash
+	}
+
+	if valid := util.CheckPrefix(header.PowHash(alg), header.Difficulty); !valid {
+		return ErrInvalidPrefix
+	}
+
+	return nil
+}
+
+// ValidateBlock validates the block on top of the state's head at now, which
+// is the time of the validating node's clock.
+//
+// Transactions are validated in order. A transaction can spend outputs created
+// by an earlier transaction of the same block, but an outpoint cannot be spent
+// twice within the block.
+func ValidateBlock(ctx context.Context, state ChainState, b *block.Block, now time.Time) error {
+	if err := CheckHeader(b.Header, state.Difficulty(), state.PowAlgorithm()); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(b.Header.PrevHash, state.HeadHash()) {
+		return ErrNotOnHead
+	}
+
+	if err := checkRules(state, b.Header, now); err != nil {
+		return err
+	}
+
+	if b.Body == nil || b.Body.CoinbaseTx == nil {
+		return ErrMissingCoinbase
+	}
+
+	if valid := b.ValidateDataHash(); !valid {
+		return ErrInvalidDataHash
+	}
+
+	txs := NewTxSet(state, b.Header.Timestamp)
+	for i, transaction := range b.Body.Txs {
+		if err := txs.Add(ctx, transaction); err != nil {
+			return errors.Wrapf(err, "transaction %d", i)
+		}
+	}
+
+	coinbase := b.Body.CoinbaseTx
+	if !coinbase.ValidateHash() {
+		return errors.Wrap(ErrInvalidTxHash, "coinbase transaction")
+	}
+
+	if !coinbase.IsCoinbase() {
+		return ErrInvalidCoinbase
+	}
+
+	// a sum wrapping around could match the reward.
+	reward, err := outputSum(coinbase)
+	if err != nil {
+		return errors.Wrap(ErrInvalidCoinbase, "coinbase outputs overflow")
+	}
+
+	if expected := block.Reward(b.Body.Txs, txs.Fees()); expected < txs.Fees() || reward != expected {
+		return ErrInvalidCoinbase
+	}
+
+	return nil
+}
+
+// checkRules validates the header against the rules of the active deployments
+// at now.
+func checkRules(state ChainState, header *block.Header, now time.Time) error {
+	if state.RuleActive(blockchain.DeploymentFutureTimestamp) &&
+		header.Timestamp.After(now.Add(blockchain.MaxFutureBlockTime)) {
+		return ErrFutureTimestamp
+	}
+
+	return nil
+}
+
+// ConnectBlock validates the block at the current time and saves it to the
+// store as the new head.
+func ConnectBlock(ctx context.Context, store ChainStore, b *block.Block) error {
+	if err := ValidateBlock(ctx, store, b, time.Now()); err != nil {
+		return err
+	}
+
+	b.Body.CoinbaseTxHash = b.Body.CoinbaseTx.Hash
+	b.Body.TxHashes = make([]hash.Hash, 0, len(b.Body.Txs))
+	for _, transaction := range b.Body.Txs {
+		b.Body.TxHashes = append(b.Body.TxHashes, transaction.Hash)
+	}
+
+	return store.SaveBlock(ctx, b)
+}
+
+// TxSet is the transactions of a block being built on top of a state. A
+// transaction is only added if it is valid after the ones added before, so
+// the set is always valid as the transactions of a block.
+type TxSet struct {
+	state      *blockState
+	at         time.Time
+	txs        []*tx.Transaction
+	size       int
+	fees       uint64
+	mintNonces map[uint64]struct{}
+}
+
+// NewTxSet creates an empty set on top of state, whose mints are checked
+// at the block time at.
+func NewTxSet(state ChainState, at time.Time) *TxSet {
+	return &TxSet{
+		state:      newBlockState(state),
+		at:         at,
+		mintNonces: make(map[uint64]struct{}),
+	}
+}
+
+// Add validates the transaction after the ones in the set, and adds it if it
+// is valid. Otherwise the set is not changed.
+func (s *TxSet) Add(ctx context.Context, transaction *tx.Transaction) error {
+	size := s.size + transaction.Size()
+	if size > blockchain.MaxBlockSize {
+		return ErrBlockTooLarge
+	}
+
+	fee, err := validateTx(ctx, s.state, transaction, s.at)
+	if err != nil {
+		return err
+	}
+
+	if s.fees+fee < s.fees {
+		return errors.Wrap(ErrUnbalancedTx, "fees overflow")
+	}
+
+	if transaction.IsMint() {
+		if _, ok := s.mintNonces[transaction.Nonce]; ok {
+			return ErrDuplicateMintNonce
+		}
+		s.mintNonces[transaction.Nonce] = struct{}{}
+		s.state.minted += transaction.OutputSum()
+	} else if err := s.state.spend(transaction.Inputs); err != nil {
+		return err
+	}
+
+	s.state.created[string(transaction.Hash)] = transaction
+	s.txs = append(s.txs, transaction)
+	s.size = size
+	s.fees += fee
+
+	return nil
+}
+
+// Txs returns the transactions in the order they are added.
+func (s *TxSet) Txs() []*tx.Transaction {
+	return s.txs
+}
+
+// Fees returns the sum of the fees the transactions pay.
+func (s *TxSet) Fees() uint64 {
+	return s.fees
+}
+
+// blockState is the chain state with the transactions of the block
+// validated so far.
+type blockState struct {
+	ChainState
+	created map[string]*tx.Transaction
+	spent   map[string]struct{}
+	// minted is the amount of the mints validated so far.
+	minted uint64
+}
+
+func newBlockState(state ChainState) *blockState {
+	return &blockState{
+		ChainState: state,
+		created:    make(map[string]*tx.Transaction),
+		spent:      make(map[string]struct{}),
+	}
+}
+
+func (s *blockState) FindTx(ctx context.Context, txHash hash.Hash) (*tx.Transaction, error) {
+	if transaction, ok := s.created[string(txHash)]; ok {
+		return transaction, nil
+	}
+	return s.ChainState.FindTx(ctx, txHash)
+}
+
+// CheckMint checks the mint together with the earlier mints of the block,
+// since the state only has the mints of the connected blocks.
+func (s *blockState) CheckMint(nonce, amount uint64, at time.Time) error {
+	if s.minted+amount < s.minted {
+		return errors.New("mints of the block overflow")
+	}
+	return s.ChainState.CheckMint(nonce, s.minted+amount, at)
+}
+
+// spend marks the outpoints of the inputs spent. It fails with ErrDoubleSpend
+// without marking any of them if one is already spent.
+func (s *blockState) spend(inputs []*tx.TxInput) error {
+	outpoints := make(map[string]struct{}, len(inputs))
+	for _, in := range inputs {
+		outpoint := fmt.Sprintf("%x:%d", in.TxHash, in.OutIdx)
+
+		_, spent := s.spent[outpoint]
+		if _, dup := outpoints[outpoint]; spent || dup {
+			return errors.Wrapf(ErrDoubleSpend, "spends %s", outpoint)
+		}
+		outpoints[outpoint] = struct{}{}
+	}
+
+	for outpoint := range outpoints {
+		s.spent[outpoint] = struct{}{}
+	}
+	return nil
+}
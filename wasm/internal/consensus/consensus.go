@@ -0,0 +1,65 @@
+// Package consensus implements the rules every node agrees on to accept
+// transactions and blocks. It does not depend on the browser, so the rules
+// can be checked natively against any ChainState.
+package consensus
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/hash"
+	"miner/internal/pow"
+	"miner/internal/tx"
+)
+
+var (
+	ErrInvalidTxHash      = errors.New("transaction hash is not valid")
+	ErrTxNotFound         = errors.New("transaction to spend is not found")
+	ErrOutIdxOutOfRange   = errors.New("outIdx cannot be reached")
+	ErrOutputSpent        = errors.New("transaction output is already spent")
+	ErrInvalidSignature   = errors.New("signature is not valid")
+	ErrUnbalancedTx       = errors.New("tx input and output does not match")
+	ErrInvalidMint        = errors.New("mint transaction is not valid")
+	ErrUnexpectedCoinbase = errors.New("coinbase transaction is not expected")
+
+	ErrInvalidVersion     = errors.New("block version is not valid")
+	ErrFutureTimestamp    = errors.New("block timestamp is too far in the future")
+	ErrDifficultyMismatch = errors.New("block difficulty does not match")
+	ErrInvalidPrefix      = errors.New("block prefix is not valid")
+	ErrInvalidBlockHash   = errors.New("hash is not valid")
+	ErrNotOnHead          = errors.New("block is not up-to-date")
+	ErrInvalidDataHash    = errors.New("block's data hash is not valid")
+	ErrBlockTooLarge      = errors.New("block transactions exceed the size limit")
+	ErrDoubleSpend        = errors.New("outpoint is spent twice in the block")
+	ErrDuplicateMintNonce = errors.New("mint nonce is used twice in the block")
+	ErrMissingCoinbase    = errors.New("coinbase transaction not found")
+	ErrInvalidCoinbase    = errors.New("coinbase transaction is fake")
+)
+
+// ChainState is the state of the chain which the rules are checked against.
+type ChainState interface {
+	// FindTx finds the stored transaction of txHash.
+	FindTx(ctx context.Context, txHash hash.Hash) (*tx.Transaction, error)
+	// HeadHash returns the hash of the current head block.
+	HeadHash() hash.Hash
+	// Difficulty returns the difficulty the next block should meet.
+	Difficulty() uint8
+	// PowAlgorithm returns the proof-of-work algorithm of the chain.
+	PowAlgorithm() pow.Algorithm
+	// RuleActive reports whether the rule of the named deployment
+	// applies to the next block.
+	RuleActive(name string) bool
+	// CheckMint checks whether the admin can issue amount of coins with nonce
+	// at the given time, which is the timestamp of the block including the mint.
+	CheckMint(nonce, amount uint64, at time.Time) error
+}
+
+// ChainStore is a ChainState which blocks can be connected to.
+type ChainStore interface {
+	ChainState
+	// SaveBlock saves the validated block and makes it the head.
+	SaveBlock(ctx context.Context, b *block.Block) error
+}
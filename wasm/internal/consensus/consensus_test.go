@@ -0,0 +1,403 @@
+package consensus_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/consensus"
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/pow"
+	"miner/internal/tx"
+)
+
+const testDifficulty = 4
+
+type memState struct {
+	txs    map[string]*tx.Transaction
+	head   hash.Hash
+	saved  []*block.Block
+	active map[string]bool
+	alg    pow.Algorithm
+	// mintChecks is the number of the CheckMint calls.
+	mintChecks int
+}
+
+func newMemState() *memState {
+	return &memState{
+		txs:    make(map[string]*tx.Transaction),
+		head:   blockchain.GenesisHash(),
+		active: make(map[string]bool),
+		alg:    pow.SHA256,
+	}
+}
+
+func (s *memState) FindTx(_ context.Context, txHash hash.Hash) (*tx.Transaction, error) {
+	if transaction, ok := s.txs[string(txHash)]; ok {
+		return transaction, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (s *memState) HeadHash() hash.Hash { return s.head }
+
+func (s *memState) Difficulty() uint8 { return testDifficulty }
+
+func (s *memState) PowAlgorithm() pow.Algorithm { return s.alg }
+
+func (s *memState) RuleActive(name string) bool { return s.active[name] }
+
+func (s *memState) CheckMint(nonce, amount uint64, at time.Time) error {
+	s.mintChecks++
+	return nil
+}
+
+func (s *memState) SaveBlock(_ context.Context, b *block.Block) error {
+	s.saved = append(s.saved, b)
+	s.head = b.Header.CurHash
+	return nil
+}
+
+type wallet struct {
+	key  *ecdsa.PrivateKey
+	addr []byte
+}
+
+func newWallet(t *testing.T) *wallet {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pub, err := privKey.PublicKey.ECDH()
+	require.NoError(t, err)
+
+	return &wallet{key: privKey, addr: pub.Bytes()}
+}
+
+// fund stores a transaction giving amount to the wallet.
+func (s *memState) fund(w *wallet, amount uint64) *tx.UTxOutput {
+	transaction := &tx.Transaction{
+		CreatedAt: time.Now(),
+		Inputs:    []*tx.TxInput{{TxHash: tx.COINBASE}},
+		Outputs:   []*tx.TxOutput{{Addr: w.addr, Amount: amount}},
+	}
+	transaction.Hash, _ = transaction.MakeHash()
+	s.txs[string(transaction.Hash)] = transaction
+
+	return &tx.UTxOutput{TxHash: transaction.Hash, OutIdx: 0, Amount: amount}
+}
+
+func (w *wallet) send(t *testing.T, from []*tx.UTxOutput, amount uint64, dst []byte) *tx.Transaction {
+	transaction, err := tx.New(from, amount, 0, w.key, w.addr, dst)
+	require.NoError(t, err)
+	return transaction
+}
+
+func mine(t *testing.T, prevHash hash.Hash, txs []*tx.Transaction) *block.Block {
+	b, err := block.New([]byte("miner"), txs, 0, prevHash, testDifficulty)
+	require.NoError(t, err)
+
+	for !util.CheckPrefix(b.Header.MakeHash(), testDifficulty) {
+		b.Header.Nonce++
+	}
+	b.Header.CurHash = b.Header.MakeHash()
+
+	return b
+}
+
+// reseal updates the hashes of the block after its coinbase is changed,
+// and mines it again.
+func reseal(t *testing.T, b *block.Block) {
+	b.Body.CoinbaseTx.Hash, _ = b.Body.CoinbaseTx.MakeHash()
+	tree, err := b.CreateMerkleTree()
+	require.NoError(t, err)
+	b.Header.DataHash = tree.MerkleRoot()
+	b.Header.Nonce = 0
+	for !util.CheckPrefix(b.Header.MakeHash(), testDifficulty) {
+		b.Header.Nonce++
+	}
+	b.Header.CurHash = b.Header.MakeHash()
+}
+
+func TestValidateTx(t *testing.T) {
+	ctx := context.Background()
+	state := newMemState()
+	alice, bob := newWallet(t), newWallet(t)
+
+	funded := state.fund(alice, 50)
+
+	t.Run("ok", func(t *testing.T) {
+		transaction := alice.send(t, []*tx.UTxOutput{funded}, 30, bob.addr)
+		assert.NoError(t, consensus.ValidateTx(ctx, state, transaction))
+	})
+
+	t.Run("fee", func(t *testing.T) {
+		transaction, err := tx.New([]*tx.UTxOutput{funded}, 30, 5, alice.key, alice.addr, bob.addr)
+		require.NoError(t, err)
+		assert.NoError(t, consensus.ValidateTx(ctx, state, transaction))
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		transaction := alice.send(t, []*tx.UTxOutput{funded}, 30, bob.addr)
+		transaction.Hash = []byte("fake")
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, transaction), consensus.ErrInvalidTxHash)
+	})
+
+	t.Run("not owner", func(t *testing.T) {
+		transaction := bob.send(t, []*tx.UTxOutput{funded}, 30, bob.addr)
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, transaction), consensus.ErrInvalidSignature)
+	})
+
+	t.Run("unbalanced", func(t *testing.T) {
+		overspent := *funded
+		overspent.Amount = 100
+
+		transaction := alice.send(t, []*tx.UTxOutput{&overspent}, 30, bob.addr)
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, transaction), consensus.ErrUnbalancedTx)
+	})
+
+	t.Run("unknown output", func(t *testing.T) {
+		unknown := &tx.UTxOutput{TxHash: []byte("unknown"), Amount: 50}
+
+		transaction := alice.send(t, []*tx.UTxOutput{unknown}, 30, bob.addr)
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, transaction), consensus.ErrTxNotFound)
+	})
+
+	t.Run("spent output", func(t *testing.T) {
+		spent := state.fund(alice, 10)
+		state.txs[string(spent.TxHash)].Outputs[0].Addr = tx.SPENT
+
+		transaction := alice.send(t, []*tx.UTxOutput{spent}, 10, bob.addr)
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, transaction), consensus.ErrOutputSpent)
+	})
+
+	t.Run("coinbase", func(t *testing.T) {
+		coinbase := state.txs[string(funded.TxHash)]
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, coinbase), consensus.ErrUnexpectedCoinbase)
+	})
+
+	t.Run("mint not signed by admin", func(t *testing.T) {
+		mint, err := tx.NewMint(alice.key, alice.addr, 100, 1)
+		require.NoError(t, err)
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, mint), consensus.ErrInvalidMint)
+	})
+
+	t.Run("mint outputs overflow", func(t *testing.T) {
+		mint, err := tx.NewMint(alice.key, alice.addr, math.MaxUint64, 2)
+		require.NoError(t, err)
+		mint.Outputs = append(mint.Outputs, &tx.TxOutput{Addr: bob.addr, Amount: 2})
+		mint.Hash, _ = mint.MakeHash()
+
+		// the wrapped sum never reaches the caps.
+		assert.ErrorIs(t, consensus.ValidateTx(ctx, state, mint), consensus.ErrUnbalancedTx)
+		assert.Zero(t, state.mintChecks)
+	})
+}
+
+func TestConnectBlock(t *testing.T) {
+	ctx := context.Background()
+	alice, bob, carol := newWallet(t), newWallet(t), newWallet(t)
+
+	t.Run("dependent transactions", func(t *testing.T) {
+		state := newMemState()
+		funded := state.fund(alice, 50)
+
+		first := alice.send(t, []*tx.UTxOutput{funded}, 50, bob.addr)
+		second := bob.send(t, []*tx.UTxOutput{{TxHash: first.Hash, OutIdx: 0, Amount: 50}}, 20, carol.addr)
+
+		b := mine(t, state.head, []*tx.Transaction{first, second})
+		require.NoError(t, consensus.ConnectBlock(ctx, state, b))
+
+		assert.Len(t, state.saved, 1)
+		assert.Equal(t, b.Header.CurHash, state.head)
+		assert.Equal(t, []hash.Hash{first.Hash, second.Hash}, b.Body.TxHashes)
+	})
+
+	t.Run("dependent transactions out of order", func(t *testing.T) {
+		state := newMemState()
+		funded := state.fund(alice, 50)
+
+		first := alice.send(t, []*tx.UTxOutput{funded}, 50, bob.addr)
+		second := bob.send(t, []*tx.UTxOutput{{TxHash: first.Hash, OutIdx: 0, Amount: 50}}, 20, carol.addr)
+
+		b := mine(t, state.head, []*tx.Transaction{second, first})
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrTxNotFound)
+		assert.Empty(t, state.saved)
+	})
+
+	t.Run("double spend", func(t *testing.T) {
+		state := newMemState()
+		funded := state.fund(alice, 50)
+
+		toBob := alice.send(t, []*tx.UTxOutput{funded}, 50, bob.addr)
+		toCarol := alice.send(t, []*tx.UTxOutput{funded}, 50, carol.addr)
+
+		b := mine(t, state.head, []*tx.Transaction{toBob, toCarol})
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrDoubleSpend)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		state := newMemState()
+		funded := state.fund(alice, 50)
+
+		transaction := alice.send(t, []*tx.UTxOutput{funded}, 50, bob.addr)
+		b := mine(t, state.head, []*tx.Transaction{transaction})
+
+		defer func(size int) { blockchain.MaxBlockSize = size }(blockchain.MaxBlockSize)
+
+		blockchain.MaxBlockSize = transaction.Size() - 1
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrBlockTooLarge)
+
+		blockchain.MaxBlockSize = transaction.Size()
+		assert.NoError(t, consensus.ConnectBlock(ctx, state, b))
+	})
+
+	t.Run("not on head", func(t *testing.T) {
+		state := newMemState()
+
+		b := mine(t, []byte("other"), nil)
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrNotOnHead)
+	})
+
+	t.Run("fake coinbase", func(t *testing.T) {
+		state := newMemState()
+		funded := state.fund(alice, 50)
+
+		b := mine(t, state.head, []*tx.Transaction{alice.send(t, []*tx.UTxOutput{funded}, 50, bob.addr)})
+
+		b.Body.CoinbaseTx.Outputs[0].Amount++
+		reseal(t, b)
+
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrInvalidCoinbase)
+	})
+
+	t.Run("fees", func(t *testing.T) {
+		state := newMemState()
+		funded := state.fund(alice, 50)
+
+		transaction, err := tx.New([]*tx.UTxOutput{funded}, 30, 5, alice.key, alice.addr, bob.addr)
+		require.NoError(t, err)
+		txs := []*tx.Transaction{transaction}
+
+		// the coinbase should take the fees.
+		b := mine(t, state.head, txs)
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrInvalidCoinbase)
+
+		b.Body.CoinbaseTx.Outputs[0].Amount = block.Reward(txs, 5)
+		reseal(t, b)
+		assert.NoError(t, consensus.ConnectBlock(ctx, state, b))
+	})
+
+	t.Run("wrapping coinbase", func(t *testing.T) {
+		state := newMemState()
+		funded := state.fund(alice, 50)
+
+		b := mine(t, state.head, []*tx.Transaction{alice.send(t, []*tx.UTxOutput{funded}, 50, bob.addr)})
+
+		// the outputs sum up to the reward modulo 2^64.
+		reward := block.Reward(b.Body.Txs, 0)
+		b.Body.CoinbaseTx.Outputs = []*tx.TxOutput{
+			{Addr: carol.addr, Amount: math.MaxUint64},
+			{Addr: carol.addr, Amount: reward + 1},
+		}
+		require.Equal(t, reward, b.Body.CoinbaseTx.OutputSum())
+		reseal(t, b)
+
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrInvalidCoinbase)
+		assert.Empty(t, state.saved)
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		state := newMemState()
+
+		b := mine(t, state.head, nil)
+		b.Header.Version = 1
+		assert.ErrorIs(t, consensus.ConnectBlock(ctx, state, b), consensus.ErrInvalidVersion)
+	})
+
+	t.Run("future timestamp", func(t *testing.T) {
+		state := newMemState()
+
+		b, err := block.New([]byte("miner"), nil, 0, state.head, testDifficulty)
+		require.NoError(t, err)
+		b.Header.Timestamp = time.Unix(1700000000, 0)
+		for !util.CheckPrefix(b.Header.MakeHash(), testDifficulty) {
+			b.Header.Nonce++
+		}
+		b.Header.CurHash = b.Header.MakeHash()
+
+		early := b.Header.Timestamp.Add(-blockchain.MaxFutureBlockTime - time.Second)
+
+		// the rule is not enforced until the deployment is active.
+		assert.NoError(t, consensus.ValidateBlock(ctx, state, b, early))
+
+		state.active[blockchain.DeploymentFutureTimestamp] = true
+		assert.ErrorIs(t, consensus.ValidateBlock(ctx, state, b, early), consensus.ErrFutureTimestamp)
+
+		// the block is valid once the clock catches up.
+		assert.NoError(t, consensus.ValidateBlock(ctx, state, b, early.Add(time.Second)))
+	})
+
+	t.Run("invalid proof of work", func(t *testing.T) {
+		state := newMemState()
+
+		b := mine(t, state.head, nil)
+		b.Header.Nonce++
+		assert.Error(t, consensus.ConnectBlock(ctx, state, b))
+	})
+}
+
+func TestCheckHeaderAlgorithm(t *testing.T) {
+	for _, alg := range []pow.Algorithm{pow.DoubleSHA256, pow.DefaultScrypt} {
+		t.Run(alg.Name(), func(t *testing.T) {
+			b, err := block.New([]byte("miner"), nil, 0, blockchain.GenesisHash(), testDifficulty)
+			require.NoError(t, err)
+
+			// the nonce should only meet the difficulty by alg.
+			for !util.CheckPrefix(b.Header.PowHash(alg), testDifficulty) ||
+				util.CheckPrefix(b.Header.MakeHash(), testDifficulty) {
+				b.Header.Nonce++
+			}
+			b.Header.CurHash = b.Header.MakeHash()
+
+			assert.NoError(t, consensus.CheckHeader(b.Header, testDifficulty, alg))
+			assert.ErrorIs(t, consensus.CheckHeader(b.Header, testDifficulty, pow.SHA256), consensus.ErrInvalidPrefix)
+		})
+	}
+}
+
+func TestTxSet(t *testing.T) {
+	ctx := context.Background()
+	state := newMemState()
+	alice, bob, carol := newWallet(t), newWallet(t), newWallet(t)
+
+	funded, other := state.fund(alice, 50), state.fund(alice, 30)
+
+	set := consensus.NewTxSet(state, time.Now())
+
+	toBob := alice.send(t, []*tx.UTxOutput{funded}, 50, bob.addr)
+	require.NoError(t, set.Add(ctx, toBob))
+
+	// a conflicting transaction is left out without spending its other inputs.
+	conflict := alice.send(t, []*tx.UTxOutput{other, funded}, 80, carol.addr)
+	assert.ErrorIs(t, set.Add(ctx, conflict), consensus.ErrDoubleSpend)
+
+	toCarol := alice.send(t, []*tx.UTxOutput{other}, 30, carol.addr)
+	require.NoError(t, set.Add(ctx, toCarol))
+
+	child := bob.send(t, []*tx.UTxOutput{{TxHash: toBob.Hash, OutIdx: 0, Amount: 50}}, 20, carol.addr)
+	require.NoError(t, set.Add(ctx, child))
+
+	assert.Equal(t, []*tx.Transaction{toBob, toCarol, child}, set.Txs())
+	assert.Zero(t, set.Fees())
+}
@@ -0,0 +1,116 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/blockchain"
+	"miner/internal/key"
+	"miner/internal/tx"
+)
+
+// ValidateTx validates a transaction to be put into the mempool.
+// Coinbase transactions are only valid as a part of a block.
+// The outputs can spend less than the inputs, and the rest is the fee paid
+// to the miner.
+func ValidateTx(ctx context.Context, state ChainState, transaction *tx.Transaction) error {
+	_, err := validateTx(ctx, state, transaction, time.Now())
+	return err
+}
+
+// validateTx validates a transaction which is minted at, if it is a mint,
+// and returns its fee.
+func validateTx(ctx context.Context, state ChainState, transaction *tx.Transaction, at time.Time) (fee uint64, err error) {
+	if isValid := transaction.ValidateHash(); !isValid {
+		return 0, ErrInvalidTxHash
+	}
+
+	if transaction.IsCoinbase() {
+		return 0, ErrUnexpectedCoinbase
+	}
+
+	if transaction.IsMint() {
+		// mints create new coins, so they pay no fee.
+		return 0, validateMint(state, transaction, at)
+	}
+
+	var in uint64
+	for i, input := range transaction.Inputs {
+		prev, err := state.FindTx(ctx, input.TxHash)
+		if err != nil {
+			return 0, errors.Wrapf(ErrTxNotFound, "input %d: %v", i, err)
+		}
+
+		if int(input.OutIdx) >= len(prev.Outputs) {
+			return 0, errors.Wrapf(ErrOutIdxOutOfRange, "input %d", i)
+		}
+
+		out := prev.Outputs[input.OutIdx]
+		if bytes.Equal(out.Addr, tx.SPENT) {
+			return 0, errors.Wrapf(ErrOutputSpent, "input %d", i)
+		}
+
+		publicKey, err := key.ParseECDSAPublicKey(out.Addr.ToHex())
+		if err != nil {
+			return 0, errors.Wrapf(ErrInvalidSignature, "input %d: failed to parse ecdsa public key: %v", i, err)
+		}
+
+		if valid := transaction.VerifyInput(i, publicKey); !valid {
+			return 0, errors.Wrapf(ErrInvalidSignature, "input %d", i)
+		}
+
+		if in+out.Amount < in {
+			return 0, ErrUnbalancedTx
+		}
+		in += out.Amount
+	}
+
+	out, err := outputSum(transaction)
+	if err != nil {
+		return 0, err
+	}
+
+	if in < out {
+		return 0, ErrUnbalancedTx
+	}
+
+	return in - out, nil
+}
+
+func validateMint(state ChainState, transaction *tx.Transaction, at time.Time) error {
+	if len(transaction.Inputs) != 1 {
+		return errors.Wrap(ErrInvalidMint, "mint transaction should have exactly one input")
+	}
+
+	// a sum wrapping around would pass the caps.
+	amount, err := outputSum(transaction)
+	if err != nil {
+		return errors.Wrap(err, "mint outputs overflow")
+	}
+
+	if !transaction.VerifyInput(0, blockchain.AdminPublicKey()) {
+		return errors.Wrap(ErrInvalidMint, "mint signature is not valid")
+	}
+
+	if err := state.CheckMint(transaction.Nonce, amount, at); err != nil {
+		return errors.Wrapf(ErrInvalidMint, "mint is not allowed: %v", err)
+	}
+
+	return nil
+}
+
+// outputSum returns the sum of the outputs' amount. Unlike
+// tx.Transaction.OutputSum, it fails with ErrUnbalancedTx on overflow.
+func outputSum(transaction *tx.Transaction) (uint64, error) {
+	var sum uint64
+	for _, output := range transaction.Outputs {
+		if sum+output.Amount < sum {
+			return 0, ErrUnbalancedTx
+		}
+		sum += output.Amount
+	}
+	return sum, nil
+}
@@ -0,0 +1,147 @@
+package issuance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/hash"
+	"miner/internal/tx"
+)
+
+var (
+	ErrNonceUsed         = errors.New("mint nonce is already used")
+	ErrPeriodCapExceeded = errors.New("mint exceeds the issuance cap of the period")
+	ErrTotalCapExceeded  = errors.New("mint exceeds the total issuance cap")
+)
+
+// Policy limits the amount of the coins the admin can issue.
+// Zero cap means there is no limit.
+type Policy struct {
+	// Period is the length of the sliding window PeriodCap applies to.
+	Period    time.Duration
+	PeriodCap uint64
+	TotalCap  uint64
+}
+
+// Mint is a record of an admin mint transaction included in the blockchain.
+type Mint struct {
+	TxHash    hash.Hash      `json:"txHash"`
+	BlockHash hash.Hash      `json:"blockHash"`
+	Nonce     uint64         `json:"nonce"`
+	Outputs   []*tx.TxOutput `json:"outputs"`
+	Amount    uint64         `json:"amount"`
+	MintedAt  time.Time      `json:"mintedAt"`
+}
+
+// Ledger keeps every mint so that the policy can be enforced
+// and the issuance can be audited.
+type Ledger struct {
+	mu     sync.RWMutex
+	policy Policy
+	mints  []*Mint
+	nonces map[uint64]struct{}
+	total  uint64
+}
+
+func NewLedger(policy Policy) *Ledger {
+	return &Ledger{
+		policy: policy,
+		nonces: make(map[uint64]struct{}),
+	}
+}
+
+// Check checks whether a mint of amount with nonce at given time is allowed.
+func (l *Ledger) Check(nonce, amount uint64, at time.Time) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.check(nonce, amount, at)
+}
+
+func (l *Ledger) check(nonce, amount uint64, at time.Time) error {
+	if _, ok := l.nonces[nonce]; ok {
+		return ErrNonceUsed
+	}
+
+	if l.policy.TotalCap != 0 && (l.total+amount < l.total || l.total+amount > l.policy.TotalCap) {
+		return ErrTotalCapExceeded
+	}
+
+	if l.policy.PeriodCap != 0 {
+		since := at.Add(-l.policy.Period)
+
+		sum := amount
+		for _, m := range l.mints {
+			if m.MintedAt.After(since) && !m.MintedAt.After(at) {
+				sum += m.Amount
+			}
+		}
+
+		if sum < amount || sum > l.policy.PeriodCap {
+			return ErrPeriodCapExceeded
+		}
+	}
+
+	return nil
+}
+
+// NewMint creates a record of the mint transaction included in the block.
+func NewMint(transaction *tx.Transaction, blockHash hash.Hash, mintedAt time.Time) *Mint {
+	return &Mint{
+		TxHash:    transaction.Hash,
+		BlockHash: blockHash,
+		Nonce:     transaction.Nonce,
+		Outputs:   transaction.Outputs,
+		Amount:    transaction.OutputSum(),
+		MintedAt:  mintedAt,
+	}
+}
+
+// Record checks the mint and adds it to the ledger.
+func (l *Ledger) Record(m *Mint) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.check(m.Nonce, m.Amount, m.MintedAt); err != nil {
+		return err
+	}
+	l.add(m)
+
+	return nil
+}
+
+// Restore adds already recorded mints without checking them.
+func (l *Ledger) Restore(mints []*Mint) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, m := range mints {
+		l.add(m)
+	}
+}
+
+func (l *Ledger) add(m *Mint) {
+	l.mints = append(l.mints, m)
+	l.nonces[m.Nonce] = struct{}{}
+	l.total += m.Amount
+}
+
+// Mints returns every recorded mint in the recorded order.
+func (l *Ledger) Mints() []*Mint {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	mints := make([]*Mint, len(l.mints))
+	copy(mints, l.mints)
+	return mints
+}
+
+// Total returns the total amount of the issued coins.
+func (l *Ledger) Total() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.total
+}
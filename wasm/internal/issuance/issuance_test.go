@@ -0,0 +1,48 @@
+package issuance_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"miner/internal/issuance"
+)
+
+func TestLedger(t *testing.T) {
+	now := time.Now()
+
+	ledger := issuance.NewLedger(issuance.Policy{
+		Period:    time.Hour,
+		PeriodCap: 100,
+		TotalCap:  200,
+	})
+
+	assert.NoError(t, ledger.Record(&issuance.Mint{Nonce: 1, Amount: 60, MintedAt: now.Add(-2 * time.Hour)}))
+	assert.NoError(t, ledger.Record(&issuance.Mint{Nonce: 2, Amount: 60, MintedAt: now}))
+
+	t.Run("nonce used", func(t *testing.T) {
+		assert.ErrorIs(t, ledger.Check(1, 1, now), issuance.ErrNonceUsed)
+	})
+
+	t.Run("period cap", func(t *testing.T) {
+		assert.ErrorIs(t, ledger.Check(3, 41, now), issuance.ErrPeriodCapExceeded)
+		assert.NoError(t, ledger.Check(3, 30, now))
+
+		// the first mint is out of the window.
+		assert.NoError(t, ledger.Check(3, 30, now.Add(-30*time.Minute)))
+	})
+
+	t.Run("total cap", func(t *testing.T) {
+		assert.ErrorIs(t, ledger.Check(3, 81, now.Add(2*time.Hour)), issuance.ErrTotalCapExceeded)
+	})
+
+	t.Run("audit", func(t *testing.T) {
+		mints := ledger.Mints()
+		if assert.Len(t, mints, 2) {
+			assert.Equal(t, uint64(1), mints[0].Nonce)
+			assert.Equal(t, uint64(2), mints[1].Nonce)
+		}
+		assert.Equal(t, uint64(120), ledger.Total())
+	})
+}
@@ -0,0 +1,106 @@
+package mempool
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultHistorySize is the number of recently mined blocks the estimator remembers.
+const DefaultHistorySize = 20
+
+// BlockStats is fee information of a mined block.
+type BlockStats struct {
+	Size       int
+	MinFeeRate float64
+}
+
+// NewBlockStats summarizes fee information of the transactions in a block.
+// The coinbase transaction should not be included in entries.
+func NewBlockStats(entries []*Entry) BlockStats {
+	var stats BlockStats
+	for i, e := range entries {
+		stats.Size += e.Size
+		if rate := e.FeeRate(); i == 0 || rate < stats.MinFeeRate {
+			stats.MinFeeRate = rate
+		}
+	}
+	return stats
+}
+
+// Estimator estimates the fee rate that a transaction needs
+// to be mined within some blocks.
+type Estimator struct {
+	mu      sync.Mutex
+	history []BlockStats
+	limit   int
+}
+
+func NewEstimator(historySize int) *Estimator {
+	return &Estimator{limit: historySize}
+}
+
+// Record records fee information of a newly mined block.
+func (e *Estimator) Record(stats BlockStats) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.history = append(e.history, stats)
+	if len(e.history) > e.limit {
+		e.history = e.history[len(e.history)-e.limit:]
+	}
+}
+
+// EstimateFee returns the fee rate(fee per byte) which is expected to be enough
+// to be included within targetBlocks blocks.
+//
+// Block capacity is assumed as the average size of the recently mined blocks.
+// The transactions in the pool which would fill up targetBlocks blocks ahead of
+// a new transaction determine the backlog rate, and the median of minimum fee
+// rates of the recent blocks works as the lower bound.
+func (e *Estimator) EstimateFee(targetBlocks int, pool *FeeIndex) float64 {
+	if targetBlocks < 1 {
+		targetBlocks = 1
+	}
+
+	e.mu.Lock()
+	history := make([]BlockStats, len(e.history))
+	copy(history, e.history)
+	e.mu.Unlock()
+
+	if len(history) == 0 {
+		return 0
+	}
+
+	var total, filledBlocks int
+	rates := make([]float64, 0, len(history))
+	for _, stats := range history {
+		rates = append(rates, stats.MinFeeRate)
+
+		// empty blocks tell nothing about the capacity.
+		if stats.Size > 0 {
+			total += stats.Size
+			filledBlocks++
+		}
+	}
+
+	sort.Float64s(rates)
+	estimate := rates[len(rates)/2]
+
+	if filledBlocks == 0 {
+		return estimate
+	}
+	capacity := total / filledBlocks * targetBlocks
+
+	var filled int
+	for _, entry := range pool.Sorted() {
+		filled += entry.Size
+		if filled > capacity {
+			if rate := entry.FeeRate(); rate > estimate {
+				estimate = rate
+			}
+			break
+		}
+	}
+
+	return estimate
+}
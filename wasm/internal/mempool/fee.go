@@ -0,0 +1,166 @@
+package mempool
+
+import (
+	"sort"
+	"sync"
+
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/tx"
+)
+
+// Entry is fee information of a transaction in the mempool.
+type Entry struct {
+	Hash hash.Hash `json:"hash"`
+	Fee  uint64    `json:"fee"`
+	Size int       `json:"size"`
+}
+
+// FeeRate returns fee per byte of the transaction.
+func (e *Entry) FeeRate() float64 {
+	if e.Size <= 0 {
+		return 0
+	}
+	return float64(e.Fee) / float64(e.Size)
+}
+
+// Bucket is a single range of the fee histogram.
+// It holds transactions whose fee rate is in [MinFeeRate, next bucket's MinFeeRate).
+type Bucket struct {
+	MinFeeRate float64 `json:"minFeeRate"`
+	Count      int     `json:"count"`
+	Size       int     `json:"size"`
+}
+
+// DefaultBuckets is the default lower bounds of the histogram buckets.
+var DefaultBuckets = []float64{0, 1, 2, 5, 10, 20, 50, 100}
+
+// FeeIndex keeps the transactions of the mempool sorted by fee rate,
+// from the highest to the lowest.
+type FeeIndex struct {
+	mu      sync.RWMutex
+	entries []*Entry
+	byHash  map[string]*Entry
+}
+
+func NewFeeIndex() *FeeIndex {
+	return &FeeIndex{byHash: make(map[string]*Entry)}
+}
+
+// Add adds the entry to the index. An entry with the same hash is replaced.
+func (idx *FeeIndex) Add(e *Entry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	key := util.BytesToStr(e.Hash.ToHex())
+	if _, ok := idx.byHash[key]; ok {
+		idx.remove(key)
+	}
+
+	rate := e.FeeRate()
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return idx.entries[i].FeeRate() < rate
+	})
+
+	idx.entries = append(idx.entries, nil)
+	copy(idx.entries[i+1:], idx.entries[i:])
+	idx.entries[i] = e
+
+	idx.byHash[key] = e
+}
+
+// Remove removes entries of given hashes. Unknown hashes are ignored.
+func (idx *FeeIndex) Remove(hashes ...hash.Hash) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, h := range hashes {
+		idx.remove(util.BytesToStr(h.ToHex()))
+	}
+}
+
+func (idx *FeeIndex) remove(key string) {
+	e, ok := idx.byHash[key]
+	if !ok {
+		return
+	}
+	delete(idx.byHash, key)
+
+	for i, cur := range idx.entries {
+		if cur == e {
+			idx.entries = append(idx.entries[:i], idx.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get returns the entry of given hash.
+func (idx *FeeIndex) Get(h hash.Hash) (*Entry, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	e, ok := idx.byHash[util.BytesToStr(h.ToHex())]
+	return e, ok
+}
+
+// Len returns the number of the entries.
+func (idx *FeeIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.entries)
+}
+
+// Sorted returns copy of the entries sorted by fee rate in descending order.
+func (idx *FeeIndex) Sorted() []*Entry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entries := make([]*Entry, len(idx.entries))
+	copy(entries, idx.entries)
+	return entries
+}
+
+// SortTxs sorts given transactions by fee rate in descending order.
+// Transactions which are not in the index are considered to have zero fee rate.
+func (idx *FeeIndex) SortTxs(txs []*tx.Transaction) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	rate := func(h hash.Hash) float64 {
+		if e, ok := idx.byHash[util.BytesToStr(h.ToHex())]; ok {
+			return e.FeeRate()
+		}
+		return 0
+	}
+
+	sort.SliceStable(txs, func(i, j int) bool {
+		return rate(txs[i].Hash) > rate(txs[j].Hash)
+	})
+}
+
+// Histogram groups the entries by fee rate.
+// bounds should be lower bounds of the buckets in ascending order.
+func (idx *FeeIndex) Histogram(bounds []float64) []Bucket {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	buckets := make([]Bucket, len(bounds))
+	for i, b := range bounds {
+		buckets[i].MinFeeRate = b
+	}
+
+	for _, e := range idx.entries {
+		rate := e.FeeRate()
+
+		i := sort.Search(len(bounds), func(i int) bool { return bounds[i] > rate }) - 1
+		if i < 0 {
+			continue
+		}
+
+		buckets[i].Count++
+		buckets[i].Size += e.Size
+	}
+
+	return buckets
+}
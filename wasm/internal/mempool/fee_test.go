@@ -0,0 +1,95 @@
+package mempool_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"miner/internal/hash"
+	"miner/internal/mempool"
+	"miner/internal/tx"
+)
+
+func TestFeeIndex(t *testing.T) {
+	idx := mempool.NewFeeIndex()
+
+	idx.Add(&mempool.Entry{Hash: []byte("low"), Fee: 100, Size: 100})
+	idx.Add(&mempool.Entry{Hash: []byte("high"), Fee: 1000, Size: 100})
+	idx.Add(&mempool.Entry{Hash: []byte("mid"), Fee: 500, Size: 100})
+
+	t.Run("sorted", func(t *testing.T) {
+		sorted := idx.Sorted()
+		if assert.Len(t, sorted, 3) {
+			assert.Equal(t, hash.Hash("high"), sorted[0].Hash)
+			assert.Equal(t, hash.Hash("mid"), sorted[1].Hash)
+			assert.Equal(t, hash.Hash("low"), sorted[2].Hash)
+		}
+	})
+
+	t.Run("sort txs", func(t *testing.T) {
+		txs := []*tx.Transaction{{Hash: []byte("unknown")}, {Hash: []byte("low")}, {Hash: []byte("high")}}
+		idx.SortTxs(txs)
+
+		assert.Equal(t, hash.Hash("high"), txs[0].Hash)
+		assert.Equal(t, hash.Hash("low"), txs[1].Hash)
+		assert.Equal(t, hash.Hash("unknown"), txs[2].Hash)
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		buckets := idx.Histogram([]float64{0, 2, 8})
+		assert.Equal(t, []mempool.Bucket{
+			{MinFeeRate: 0, Count: 1, Size: 100},
+			{MinFeeRate: 2, Count: 1, Size: 100},
+			{MinFeeRate: 8, Count: 1, Size: 100},
+		}, buckets)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		idx.Remove([]byte("mid"), []byte("unknown"))
+		assert.Equal(t, 2, idx.Len())
+
+		_, ok := idx.Get([]byte("mid"))
+		assert.False(t, ok)
+	})
+}
+
+func TestEstimateFee(t *testing.T) {
+	pool := mempool.NewFeeIndex()
+	estimator := mempool.NewEstimator(mempool.DefaultHistorySize)
+
+	assert.Zero(t, estimator.EstimateFee(1, pool))
+
+	estimator.Record(mempool.NewBlockStats([]*mempool.Entry{
+		{Fee: 200, Size: 100},
+		{Fee: 300, Size: 100},
+	}))
+
+	t.Run("no backlog", func(t *testing.T) {
+		assert.Equal(t, float64(2), estimator.EstimateFee(1, pool))
+	})
+
+	for i, fee := range []uint64{1000, 900, 800, 700} {
+		pool.Add(&mempool.Entry{Hash: []byte{byte(i)}, Fee: fee, Size: 100})
+	}
+
+	t.Run("backlog", func(t *testing.T) {
+		// each block holds 200 bytes, so the third transaction waits for the next block.
+		assert.Equal(t, float64(8), estimator.EstimateFee(1, pool))
+		assert.Equal(t, float64(2), estimator.EstimateFee(2, pool))
+	})
+}
+
+func TestOrderByDependency(t *testing.T) {
+	parent := &tx.Transaction{Hash: []byte("parent")}
+	child := &tx.Transaction{
+		Hash:   []byte("child"),
+		Inputs: []*tx.TxInput{{TxHash: []byte("parent")}},
+	}
+	other := &tx.Transaction{
+		Hash:   []byte("other"),
+		Inputs: []*tx.TxInput{{TxHash: []byte("confirmed")}},
+	}
+
+	ordered := mempool.OrderByDependency([]*tx.Transaction{child, other, parent})
+	assert.Equal(t, []*tx.Transaction{parent, child, other}, ordered)
+}
@@ -0,0 +1,39 @@
+package mempool
+
+import "miner/internal/tx"
+
+// OrderByDependency reorders txs so that each transaction comes after the
+// transactions in txs whose outputs it spends. Otherwise the given order,
+// such as the fee rate order, is kept.
+func OrderByDependency(txs []*tx.Transaction) []*tx.Transaction {
+	byHash := make(map[string]*tx.Transaction, len(txs))
+	for _, t := range txs {
+		byHash[string(t.Hash)] = t
+	}
+
+	ordered := make([]*tx.Transaction, 0, len(txs))
+	visited := make(map[string]bool, len(txs))
+
+	var visit func(t *tx.Transaction)
+	visit = func(t *tx.Transaction) {
+		key := string(t.Hash)
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+
+		for _, in := range t.Inputs {
+			if parent, ok := byHash[string(in.TxHash)]; ok {
+				visit(parent)
+			}
+		}
+
+		ordered = append(ordered, t)
+	}
+
+	for _, t := range txs {
+		visit(t)
+	}
+
+	return ordered
+}
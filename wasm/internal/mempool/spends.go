@@ -0,0 +1,145 @@
+package mempool
+
+import (
+	"fmt"
+	"sync"
+
+	"miner/internal/hash"
+	"miner/internal/tx"
+)
+
+// Spends indexes the outputs spent by the mempool transactions, so that the
+// transactions spending the same output can be found. It is safe for
+// concurrent use.
+type Spends struct {
+	mu sync.RWMutex
+	// spenders are the transactions by the outpoints they spend. An outpoint
+	// has several spenders only if the mempool is stored with conflicts.
+	spenders map[string]map[string]struct{}
+	// inputs are the inputs by the transactions.
+	inputs map[string][]*tx.TxInput
+	// children are the transactions by the transactions whose outputs they spend.
+	children map[string]map[string]struct{}
+}
+
+func NewSpends() *Spends {
+	return &Spends{
+		spenders: make(map[string]map[string]struct{}),
+		inputs:   make(map[string][]*tx.TxInput),
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+func outpoint(in *tx.TxInput) string {
+	return fmt.Sprintf("%x:%d", in.TxHash, in.OutIdx)
+}
+
+// Add indexes the outputs spent by the transaction. Mints spend nothing.
+func (s *Spends) Add(t *tx.Transaction) {
+	if t.IsMint() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := string(t.Hash)
+	if _, ok := s.inputs[key]; ok {
+		return
+	}
+
+	for _, in := range t.Inputs {
+		addKey(s.spenders, outpoint(in), key)
+		addKey(s.children, string(in.TxHash), key)
+	}
+	s.inputs[key] = t.Inputs
+}
+
+// Spent reports whether an indexed transaction spends the output.
+func (s *Spends) Spent(txHash hash.Hash, outIdx uint16) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.spenders[outpoint(&tx.TxInput{TxHash: txHash, OutIdx: outIdx})]
+	return ok
+}
+
+// Remove removes the transactions of given hashes. Unknown hashes are ignored.
+func (s *Spends) Remove(hashes ...hash.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range hashes {
+		key := string(h)
+		for _, in := range s.inputs[key] {
+			removeKey(s.spenders, outpoint(in), key)
+			removeKey(s.children, string(in.TxHash), key)
+		}
+		delete(s.inputs, key)
+	}
+}
+
+// Conflicts returns the indexed transactions, except txs, which spend an
+// output spent by txs, and the ones spending their outputs in turn. They can
+// never be mined together with txs.
+func (s *Spends) Conflicts(txs []*tx.Transaction) []hash.Hash {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	own := make(map[string]struct{}, len(txs))
+	for _, t := range txs {
+		own[string(t.Hash)] = struct{}{}
+	}
+
+	var queue []string
+	found := make(map[string]struct{})
+	visit := func(key string) {
+		if _, ok := own[key]; ok {
+			return
+		}
+		if _, ok := found[key]; ok {
+			return
+		}
+		found[key] = struct{}{}
+		queue = append(queue, key)
+	}
+
+	for _, t := range txs {
+		if t.IsMint() {
+			continue
+		}
+		for _, in := range t.Inputs {
+			for spender := range s.spenders[outpoint(in)] {
+				visit(spender)
+			}
+		}
+	}
+
+	conflicts := make([]hash.Hash, 0, len(queue))
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+
+		conflicts = append(conflicts, hash.Hash(key))
+		for child := range s.children[key] {
+			visit(child)
+		}
+	}
+
+	return conflicts
+}
+
+func addKey(sets map[string]map[string]struct{}, key, member string) {
+	if sets[key] == nil {
+		sets[key] = make(map[string]struct{})
+	}
+	sets[key][member] = struct{}{}
+}
+
+func removeKey(sets map[string]map[string]struct{}, key, member string) {
+	if set := sets[key]; set != nil {
+		if delete(set, member); len(set) == 0 {
+			delete(sets, key)
+		}
+	}
+}
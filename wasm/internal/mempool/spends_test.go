@@ -0,0 +1,58 @@
+package mempool_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"miner/internal/hash"
+	"miner/internal/mempool"
+	"miner/internal/tx"
+)
+
+func TestSpends(t *testing.T) {
+	spending := func(h string, inputs ...*tx.TxInput) *tx.Transaction {
+		return &tx.Transaction{Hash: []byte(h), Inputs: inputs}
+	}
+
+	parent := spending("parent", &tx.TxInput{TxHash: []byte("funding"), OutIdx: 0})
+	child := spending("child", &tx.TxInput{TxHash: []byte("parent"), OutIdx: 1})
+	other := spending("other", &tx.TxInput{TxHash: []byte("funding"), OutIdx: 1})
+
+	spends := mempool.NewSpends()
+	for _, t := range []*tx.Transaction{parent, child, other} {
+		spends.Add(t)
+	}
+
+	t.Run("spent", func(t *testing.T) {
+		assert.True(t, spends.Spent([]byte("parent"), 1))
+		assert.False(t, spends.Spent([]byte("parent"), 0))
+	})
+
+	t.Run("no conflict", func(t *testing.T) {
+		assert.Empty(t, spends.Conflicts([]*tx.Transaction{parent, other}))
+		assert.Empty(t, spends.Conflicts([]*tx.Transaction{
+			spending("unrelated", &tx.TxInput{TxHash: []byte("funding"), OutIdx: 2}),
+		}))
+	})
+
+	t.Run("descendants", func(t *testing.T) {
+		conflict := spending("conflict", &tx.TxInput{TxHash: []byte("funding"), OutIdx: 0})
+		assert.Equal(t, []hash.Hash{hash.Hash("parent"), hash.Hash("child")}, spends.Conflicts([]*tx.Transaction{conflict}))
+	})
+
+	t.Run("mint", func(t *testing.T) {
+		mint := &tx.Transaction{Hash: []byte("mint"), Inputs: []*tx.TxInput{{TxHash: tx.ADMIN}}}
+		spends.Add(mint)
+		assert.Empty(t, spends.Conflicts([]*tx.Transaction{
+			{Hash: []byte("mint2"), Inputs: []*tx.TxInput{{TxHash: tx.ADMIN}}},
+		}))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		spends.Remove(hash.Hash("parent"), hash.Hash("child"))
+
+		conflict := spending("conflict", &tx.TxInput{TxHash: []byte("funding"), OutIdx: 0})
+		assert.Empty(t, spends.Conflicts([]*tx.Transaction{conflict}))
+	})
+}
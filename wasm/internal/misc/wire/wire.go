@@ -0,0 +1,159 @@
+// Package wire implements primitives of the canonical binary encoding which is
+// used for hashing, storing and relaying transactions and blocks.
+//
+// Every integer is little endian with a fixed width, except lengths and counts
+// which are unsigned varints(encoding/binary's Uvarint). Byte strings are
+// prefixed with their length. There is no padding and no optional field, so
+// each value has exactly one encoding.
+package wire
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnexpectedEOF = errors.New("unexpected end of data")
+	ErrTrailingData  = errors.New("trailing data after value")
+	ErrNonCanonical  = errors.New("value is not canonically encoded")
+)
+
+// Writer appends canonically encoded values to its buffer.
+type Writer struct {
+	buf []byte
+}
+
+func (w *Writer) Uint8(v uint8) { w.buf = append(w.buf, v) }
+
+func (w *Writer) Uint16(v uint16) { w.buf = binary.LittleEndian.AppendUint16(w.buf, v) }
+
+func (w *Writer) Uint32(v uint32) { w.buf = binary.LittleEndian.AppendUint32(w.buf, v) }
+
+func (w *Writer) Uint64(v uint64) { w.buf = binary.LittleEndian.AppendUint64(w.buf, v) }
+
+func (w *Writer) Int64(v int64) { w.Uint64(uint64(v)) }
+
+// Count writes length of the following list.
+func (w *Writer) Count(n int) { w.buf = binary.AppendUvarint(w.buf, uint64(n)) }
+
+// Bytes writes length prefixed bytes.
+func (w *Writer) Bytes(b []byte) {
+	w.Count(len(b))
+	w.buf = append(w.buf, b...)
+}
+
+// Raw writes b as it is. b should be an encoded value.
+func (w *Writer) Raw(b []byte) { w.buf = append(w.buf, b...) }
+
+// Result returns the written bytes.
+func (w *Writer) Result() []byte { return w.buf }
+
+// Reader reads canonically encoded values.
+// Once an error occurs, every following read returns zero value
+// and Err reports the first error.
+type Reader struct {
+	buf []byte
+	err error
+}
+
+func NewReader(b []byte) *Reader { return &Reader{buf: b} }
+
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = ErrUnexpectedEOF
+		return nil
+	}
+
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *Reader) Uint8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *Reader) Uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *Reader) Uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *Reader) Uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *Reader) Int64() int64 { return int64(r.Uint64()) }
+
+// Count reads length of the following list. Each element of the list should
+// take at least minSize bytes, so that a corrupted count cannot make the caller
+// allocate more than the remaining data.
+func (r *Reader) Count(minSize int) int {
+	if r.err != nil {
+		return 0
+	}
+
+	n, read := binary.Uvarint(r.buf)
+	if read <= 0 {
+		r.err = ErrUnexpectedEOF
+		return 0
+	}
+
+	// varint with redundant trailing zero bytes is not canonical.
+	if read != len(binary.AppendUvarint(nil, n)) {
+		r.err = ErrNonCanonical
+		return 0
+	}
+	r.buf = r.buf[read:]
+
+	if minSize < 1 {
+		minSize = 1
+	}
+	if n > uint64(len(r.buf)/minSize) {
+		r.err = ErrUnexpectedEOF
+		return 0
+	}
+
+	return int(n)
+}
+
+// Bytes reads length prefixed bytes. The result is a copy,
+// and empty bytes are read as nil.
+func (r *Reader) Bytes() []byte {
+	n := r.Count(1)
+	b := r.next(n)
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// Err returns the first error occurred.
+func (r *Reader) Err() error { return r.err }
+
+// Finish returns the first error occurred, or ErrTrailingData
+// if there is unread data.
+func (r *Reader) Finish() error {
+	if r.err == nil && len(r.buf) != 0 {
+		r.err = ErrTrailingData
+	}
+	return r.err
+}
@@ -0,0 +1,265 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/consensus"
+	"miner/internal/hash"
+	"miner/internal/issuance"
+	"miner/internal/mempool"
+	"miner/internal/storage"
+	"miner/internal/tx"
+)
+
+var ErrStaleBlock = errors.New("block is not up-to-date")
+
+// NewBlockTemplate creates a block on the current head with the mempool
+// transactions of txHashes, or every mempool transaction if txHashes is empty.
+func (n *Node) NewBlockTemplate(ctx context.Context, txHashes []hash.Hash) (*block.Block, error) {
+	txs, fees, err := n.FindTemplateTxs(ctx, txHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := block.New(
+		blockchain.MinerAddr, txs, fees,
+		blockchain.HeadHash, blockchain.Difficulty,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create block")
+	}
+	b.Header.Version = n.Deployments.Version()
+
+	return b, nil
+}
+
+// FindTemplateTxs finds the mempool transactions of txHashes, or every mempool
+// transaction if txHashes is empty, in the order they can be mined, and the
+// sum of their fees. The ones paying the highest fee rates are taken until the
+// block is full. The ones which are no longer valid, or conflict with the ones
+// taken before, are left out, so that the block can be connected.
+func (n *Node) FindTemplateTxs(ctx context.Context, txHashes []hash.Hash) ([]*tx.Transaction, uint64, error) {
+	if len(txHashes) == 0 {
+		for _, entry := range n.FeeIndex.Sorted() {
+			txHashes = append(txHashes, entry.Hash.ToHex())
+		}
+	}
+
+	txs, err := storage.FindTxsFromMempool(ctx, txHashes)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to find txs")
+	}
+	n.FeeIndex.SortTxs(txs)
+
+	set := consensus.NewTxSet(n, time.Now())
+	for _, transaction := range mempool.OrderByDependency(txs) {
+		// the rest are still tried, e.g. a smaller one may fit.
+		_ = set.Add(ctx, transaction)
+	}
+
+	return set.Txs(), set.Fees(), nil
+}
+
+// ReceiveBlock connects the block received from a peer, and the orphans
+// waiting for it. A block whose parent is unknown is kept as an orphan until
+// the parent arrives, and one whose parent is not the head fails with
+// ErrStaleBlock.
+func (n *Node) ReceiveBlock(ctx context.Context, b *block.Block) error {
+	if err := consensus.CheckHeader(b.Header, n.Difficulty(), n.PowAlgorithm()); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(b.Header.PrevHash, blockchain.HeadHash) {
+		known, err := storage.HasBlockHeader(ctx, b.Header.PrevHash)
+		if err != nil {
+			return errors.Wrap(err, "failed to find parent block")
+		}
+
+		if known {
+			return ErrStaleBlock
+		}
+
+		// parent has not arrived yet. keep the block until it does.
+		n.Orphans.Add(b)
+		return nil
+	}
+
+	return n.CommitMinedBlock(ctx, b)
+}
+
+// CommitMinedBlock validates the block on the head and connects it, and the
+// orphans waiting for it. Every block mined or received on the head should be
+// committed by it, so that the orphans are not left behind.
+func (n *Node) CommitMinedBlock(ctx context.Context, b *block.Block) error {
+	if err := consensus.ConnectBlock(ctx, n, b); err != nil {
+		return err
+	}
+
+	n.ConnectOrphans(ctx, b.Header.CurHash)
+
+	return nil
+}
+
+// ConnectOrphans connects the orphans descending from the block of parentHash.
+func (n *Node) ConnectOrphans(ctx context.Context, parentHash hash.Hash) {
+	queue := []hash.Hash{parentHash}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, child := range n.Orphans.TakeChildren(parent) {
+			// a sibling may already be connected.
+			if !bytes.Equal(child.Header.PrevHash, blockchain.HeadHash) {
+				continue
+			}
+
+			if err := consensus.ConnectBlock(ctx, n, child); err != nil {
+				n.cfg.OnError(errors.Wrap(err, "failed to connect orphan block"))
+				continue
+			}
+
+			queue = append(queue, child.Header.CurHash)
+		}
+	}
+}
+
+// SaveBlock stores the block and makes it the head.
+func (n *Node) SaveBlock(ctx context.Context, block *block.Block) error {
+	if err := storage.InsertBlockHeader(ctx, block.Header); err != nil {
+		return errors.Wrap(err, "failed to insert block header")
+	}
+
+	// copy block body so we can use original one later.
+	newBlockBody := *block.Body
+	newBlockBody.CoinbaseTx = nil
+	newBlockBody.Txs = nil
+
+	if err := storage.InsertBlockBody(ctx, block.Header.CurHash, &newBlockBody); err != nil {
+		return errors.Wrap(err, "failed to insert block body")
+	}
+
+	// transactions are inserted first since they may spend outputs
+	// created within the same block.
+	if err := storage.InsertTxs(ctx, append(block.Body.Txs, block.Body.CoinbaseTx)); err != nil {
+		return errors.Wrap(err, "failed to insert transactions")
+	}
+
+	// mints are recorded once the block is stored,
+	// so that their nonces are not used up by a block failed to store.
+	if err := n.recordMints(ctx, block); err != nil {
+		return err
+	}
+
+	if err := n.recordBlockFees(ctx, block); err != nil {
+		return errors.Wrap(err, "failed to record block fees")
+	}
+
+	if err := deleteUsedTxOutputs(ctx, block); err != nil {
+		return errors.Wrap(err, "failed to delete used tx outputs")
+	}
+
+	// the mempool transactions spending the same outputs as the block can
+	// never be mined, and neither can the ones spending their outputs.
+	evicted := append(n.Spends.Conflicts(block.Body.Txs), block.Body.TxHashes...)
+
+	if err := storage.DeleteTxsFromMempool(ctx, evicted); err != nil {
+		return errors.Wrap(err, "failed to delete txs from mempool")
+	}
+	n.FeeIndex.Remove(evicted...)
+	n.Spends.Remove(evicted...)
+	n.Deployments.Connect(block.Header.Version)
+
+	n.SetHead(block.Header.CurHash)
+
+	return nil
+}
+
+// recordMints records the block's mint transactions to the ledger.
+func (n *Node) recordMints(ctx context.Context, block *block.Block) error {
+	for _, transaction := range block.Body.Txs {
+		if !transaction.IsMint() {
+			continue
+		}
+
+		mint := issuance.NewMint(transaction, block.Header.CurHash, block.Header.Timestamp)
+		if err := n.MintLedger.Record(mint); err != nil {
+			return errors.Wrap(err, "mint is not allowed")
+		}
+
+		if err := storage.InsertMint(ctx, mint); err != nil {
+			return errors.Wrap(err, "failed to insert mint")
+		}
+	}
+
+	return nil
+}
+
+func deleteUsedTxOutputs(ctx context.Context, block *block.Block) error {
+	// the same transaction can be spent by several inputs,
+	// so every change should be applied to a single copy.
+	targets := make(map[string]*tx.Transaction)
+	order := make([]string, 0)
+
+	for _, transaction := range block.Body.Txs {
+		if transaction.IsMint() {
+			continue
+		}
+
+		for _, in := range transaction.Inputs {
+			key := string(in.TxHash)
+
+			targetTx, ok := targets[key]
+			if !ok {
+				var err error
+				targetTx, err = storage.FindTx(ctx, in.TxHash)
+				if err != nil {
+					return errors.Wrap(err, "failed to find transaction to classify")
+				}
+
+				targets[key] = targetTx
+				order = append(order, key)
+			}
+
+			targetTx.Outputs[in.OutIdx].Addr = tx.SPENT
+		}
+	}
+
+	updatable := make([]*tx.Transaction, 0, len(targets))
+	deletable := make([][]byte, 0, len(targets))
+
+	for _, key := range order {
+		targetTx := targets[key]
+
+		// check if all the tx outputs are used.
+		if empty := checkTxEmpty(targetTx); empty {
+			deletable = append(deletable, targetTx.Hash)
+			continue
+		}
+		updatable = append(updatable, targetTx)
+	}
+
+	if err := storage.UpdateTxs(ctx, updatable); err != nil {
+		return errors.Wrap(err, "failed to update txs")
+	}
+
+	if err := storage.DeleteTxs(ctx, deletable); err != nil {
+		return errors.Wrap(err, "failed to delete txs")
+	}
+
+	return nil
+}
+
+func checkTxEmpty(transaction *tx.Transaction) bool {
+	for _, out := range transaction.Outputs {
+		if !bytes.Equal(tx.SPENT, out.Addr) {
+			return false
+		}
+	}
+	return true
+}
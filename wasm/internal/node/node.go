@@ -0,0 +1,179 @@
+// Package node implements the full node shared by the browser miner and the
+// native node. It keeps the state derived from the stored chain in memory,
+// and connects the blocks and the transactions it receives.
+package node
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/hash"
+	"miner/internal/issuance"
+	"miner/internal/mempool"
+	"miner/internal/orphan"
+	"miner/internal/pow"
+	"miner/internal/storage"
+	"miner/internal/tx"
+	"miner/internal/versionbits"
+)
+
+// ChainAlgorithm returns the proof-of-work algorithm of the chain parameters.
+func ChainAlgorithm() pow.Algorithm {
+	alg, err := pow.Lookup(blockchain.PowAlgorithm)
+	if err != nil {
+		// the chain parameters are compiled in, so this is a programming error.
+		panic(err)
+	}
+	return alg
+}
+
+// Config is the hooks of the node. Every hook is optional.
+type Config struct {
+	// OnHeadChanged is called when the head changes, e.g. to stop mining on
+	// the previous one.
+	OnHeadChanged func()
+	// OnMempoolChanged is called when a transaction enters the mempool.
+	OnMempoolChanged func()
+	// RequestParent is called with the hash of the missing parent whenever
+	// an orphan block arrives.
+	RequestParent func(parentHash hash.Hash)
+	// OnError is called with the failures which have no caller to report to.
+	OnError func(err error)
+}
+
+// Node is the consensus.ChainStore backed by the storage. The head is
+// blockchain.HeadHash.
+type Node struct {
+	cfg Config
+	alg pow.Algorithm
+
+	Deployments  *versionbits.Tracker
+	FeeIndex     *mempool.FeeIndex
+	Spends       *mempool.Spends
+	FeeEstimator *mempool.Estimator
+	MintLedger   *issuance.Ledger
+	Orphans      *orphan.Pool
+}
+
+func New(cfg Config) *Node {
+	if cfg.OnHeadChanged == nil {
+		cfg.OnHeadChanged = func() {}
+	}
+	if cfg.OnMempoolChanged == nil {
+		cfg.OnMempoolChanged = func() {}
+	}
+	if cfg.RequestParent == nil {
+		cfg.RequestParent = func(hash.Hash) {}
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(error) {}
+	}
+
+	return &Node{
+		cfg:          cfg,
+		alg:          ChainAlgorithm(),
+		Deployments:  versionbits.NewTracker(blockchain.Deployments),
+		FeeIndex:     mempool.NewFeeIndex(),
+		Spends:       mempool.NewSpends(),
+		FeeEstimator: mempool.NewEstimator(mempool.DefaultHistorySize),
+		MintLedger:   issuance.NewLedger(blockchain.MintPolicy),
+		Orphans:      orphan.NewPool(orphan.DefaultLimit, orphan.DefaultTTL, cfg.RequestParent),
+	}
+}
+
+// Load restores the head and the state derived from the stored chain.
+// The storage should be initialized before.
+func (n *Node) Load(ctx context.Context) error {
+	head, err := storage.FindBlockchainHead()
+	if err != nil {
+		return errors.Wrap(err, "failed to find head")
+	}
+	blockchain.HeadHash = head
+
+	if err := n.loadDeployments(ctx); err != nil {
+		return err
+	}
+
+	if err := n.loadMempool(ctx); err != nil {
+		return err
+	}
+
+	return n.loadMintLedger(ctx)
+}
+
+// loadDeployments replays the versions of the stored blocks
+// from the genesis to the head.
+func (n *Node) loadDeployments(ctx context.Context) error {
+	headers, err := storage.FindBlockHeaders(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to find block headers")
+	}
+
+	children := make(map[string]*block.Header, len(headers))
+	for _, header := range headers {
+		// genesis refers itself as its parent.
+		if bytes.Equal(header.CurHash, blockchain.GenesisHash()) {
+			continue
+		}
+		children[string(header.PrevHash)] = header
+	}
+
+	cur := blockchain.GenesisHash()
+	for !bytes.Equal(cur, blockchain.HeadHash) {
+		header, ok := children[string(cur)]
+		if !ok {
+			return errors.Errorf("block after %x is not found", cur)
+		}
+
+		n.Deployments.Connect(header.Version)
+		cur = header.CurHash
+	}
+
+	return nil
+}
+
+// loadMintLedger restores the ledger from the stored mints.
+func (n *Node) loadMintLedger(ctx context.Context) error {
+	mints, err := storage.FindMints(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to find mints")
+	}
+
+	n.MintLedger.Restore(mints)
+	return nil
+}
+
+// SetHead makes the block of headHash the head.
+func (n *Node) SetHead(headHash hash.Hash) {
+	blockchain.HeadHash = headHash
+	n.cfg.OnHeadChanged()
+}
+
+func (n *Node) FindTx(ctx context.Context, txHash hash.Hash) (*tx.Transaction, error) {
+	return storage.FindTx(ctx, txHash)
+}
+
+func (n *Node) HeadHash() hash.Hash {
+	return blockchain.HeadHash
+}
+
+func (n *Node) Difficulty() uint8 {
+	return blockchain.Difficulty
+}
+
+func (n *Node) PowAlgorithm() pow.Algorithm {
+	return n.alg
+}
+
+func (n *Node) RuleActive(name string) bool {
+	return n.Deployments.Active(name)
+}
+
+func (n *Node) CheckMint(nonce, amount uint64, at time.Time) error {
+	return n.MintLedger.Check(nonce, amount, at)
+}
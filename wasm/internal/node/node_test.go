@@ -0,0 +1,286 @@
+//go:build !(js && wasm)
+
+package node_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/hash"
+	"miner/internal/node"
+	"miner/internal/processor"
+	"miner/internal/storage"
+	"miner/internal/tx"
+)
+
+type hooks struct {
+	headChanged    int
+	mempoolChanged int
+	requested      []hash.Hash
+}
+
+func newNode(t *testing.T, h *hooks) (context.Context, *node.Node) {
+	ctx := context.Background()
+
+	storage.Dir = t.TempDir()
+	require.NoError(t, storage.InitDB(ctx))
+
+	n := node.New(node.Config{
+		OnHeadChanged:    func() { h.headChanged++ },
+		OnMempoolChanged: func() { h.mempoolChanged++ },
+		RequestParent:    func(parentHash hash.Hash) { h.requested = append(h.requested, parentHash) },
+		OnError:          func(err error) { t.Error(err) },
+	})
+	require.NoError(t, n.Load(ctx))
+
+	return ctx, n
+}
+
+func newWallet(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pub, err := privKey.PublicKey.ECDH()
+	require.NoError(t, err)
+
+	return privKey, pub.Bytes()
+}
+
+func mine(t *testing.T, b *block.Block) *block.Block {
+	r, err := processor.CPUMiner{Algorithm: node.ChainAlgorithm()}.
+		Mine(context.Background(), b.Header.MakeHashInput(), b.Header.Difficulty, processor.Options{})
+	require.NoError(t, err)
+
+	b.Header.Nonce = r.Nonce
+	b.Header.CurHash = b.Header.MakeHash()
+	return b
+}
+
+func mineOn(t *testing.T, minerAddr []byte, prevHash hash.Hash) *block.Block {
+	b, err := block.New(minerAddr, nil, 0, prevHash, blockchain.Difficulty)
+	require.NoError(t, err)
+	return mine(t, b)
+}
+
+func TestReceiveBlock(t *testing.T) {
+	var h hooks
+	ctx, n := newNode(t, &h)
+	_, addr := newWallet(t)
+
+	b1 := mineOn(t, addr, blockchain.GenesisHash())
+	b2 := mineOn(t, addr, b1.Header.CurHash)
+
+	// the parent of b2 is unknown, so it waits for b1.
+	require.NoError(t, n.ReceiveBlock(ctx, b2))
+	assert.Equal(t, []hash.Hash{b1.Header.CurHash}, h.requested)
+	assert.Equal(t, hash.Hash(blockchain.GenesisHash()), n.HeadHash())
+
+	require.NoError(t, n.ReceiveBlock(ctx, b1))
+	assert.Equal(t, b2.Header.CurHash, n.HeadHash())
+	assert.Equal(t, 2, h.headChanged)
+
+	stale := mineOn(t, addr, b1.Header.CurHash)
+	assert.ErrorIs(t, n.ReceiveBlock(ctx, stale), node.ErrStaleBlock)
+
+	// a block mined locally connects the orphans waiting for it too.
+	b3 := mineOn(t, addr, b2.Header.CurHash)
+	b4 := mineOn(t, addr, b3.Header.CurHash)
+	require.NoError(t, n.ReceiveBlock(ctx, b4))
+	require.NoError(t, n.CommitMinedBlock(ctx, b3))
+	assert.Equal(t, b4.Header.CurHash, n.HeadHash())
+
+	// another node restores the head from the storage.
+	blockchain.HeadHash = nil
+	require.NoError(t, node.New(node.Config{}).Load(ctx))
+	assert.Equal(t, []byte(b4.Header.CurHash), blockchain.HeadHash)
+}
+
+// mineMempool mines the mempool transactions in a block to minerAddr.
+func mineMempool(t *testing.T, ctx context.Context, n *node.Node, minerAddr []byte) *block.Block {
+	blockchain.MinerAddr = minerAddr
+	b, err := n.NewBlockTemplate(ctx, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, n.ReceiveBlock(ctx, mine(t, b)))
+	return b
+}
+
+func TestAddTx(t *testing.T) {
+	var h hooks
+	ctx, n := newNode(t, &h)
+	privKey, addr := newWallet(t)
+	_, dst := newWallet(t)
+
+	// empty blocks earn nothing, so the wallet is funded with a block
+	// of a transaction spending nothing.
+	funding, err := tx.New(nil, 0, 0, privKey, addr, dst)
+	require.NoError(t, err)
+
+	require.NoError(t, n.AddTx(ctx, funding))
+	assert.Equal(t, 1, h.mempoolChanged)
+	assert.Equal(t, 1, n.FeeIndex.Len())
+
+	b := mineMempool(t, ctx, n, addr)
+	require.Len(t, b.Body.Txs, 1)
+	assert.Equal(t, funding.Hash, b.Body.Txs[0].Hash)
+	assert.Equal(t, 0, n.FeeIndex.Len())
+
+	uTxOuts, got, err := storage.FindUTxOutputs(ctx, addr)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(blockchain.MiningPrize), got)
+
+	const fee = 2
+	transaction, err := tx.New(uTxOuts, blockchain.MiningPrize/2, fee, privKey, addr, dst)
+	require.NoError(t, err)
+	require.NoError(t, n.AddTx(ctx, transaction))
+	assert.Equal(t, uint64(blockchain.MiningPrize+fee), n.MempoolValue())
+
+	// a transaction is only added once.
+	assert.ErrorIs(t, n.AddTx(ctx, transaction), storage.ErrKeyExists)
+
+	// the fee goes to the miner.
+	_, miner := newWallet(t)
+	b = mineMempool(t, ctx, n, miner)
+	assert.Equal(t, uint64(blockchain.MiningPrize+fee), b.Body.CoinbaseTx.OutputSum())
+	assert.Greater(t, n.FeeEstimator.EstimateFee(1, n.FeeIndex), float64(0))
+
+	_, got, err = storage.FindUTxOutputs(ctx, dst)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(blockchain.MiningPrize/2), got)
+
+	_, got, err = storage.FindUTxOutputs(ctx, miner)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(blockchain.MiningPrize+fee), got)
+}
+
+// fund mines blocks until the wallet of addr has several outputs,
+// and returns them.
+func fund(t *testing.T, ctx context.Context, n *node.Node, privKey *ecdsa.PrivateKey, addr []byte) []*tx.UTxOutput {
+	// empty blocks earn nothing, so a transaction spending nothing is mined.
+	funding, err := tx.New(nil, 0, 0, privKey, addr, addr)
+	require.NoError(t, err)
+	require.NoError(t, n.AddTx(ctx, funding))
+	mineMempool(t, ctx, n, addr)
+
+	// the reward is split to the wallet itself.
+	uTxOuts, _, err := storage.FindUTxOutputs(ctx, addr)
+	require.NoError(t, err)
+	split, err := tx.New(uTxOuts, blockchain.MiningPrize/2, 0, privKey, addr, addr)
+	require.NoError(t, err)
+	require.NoError(t, n.AddTx(ctx, split))
+	mineMempool(t, ctx, n, addr)
+
+	uTxOuts, _, err = storage.FindUTxOutputs(ctx, addr)
+	require.NoError(t, err)
+	require.Len(t, uTxOuts, 3)
+	return uTxOuts
+}
+
+func TestNewBlockTemplate(t *testing.T) {
+	var h hooks
+	ctx, n := newNode(t, &h)
+	privKey, addr := newWallet(t)
+	_, dst := newWallet(t)
+
+	uTxOuts := fund(t, ctx, n, privKey, addr)
+
+	t.Run("fee rate", func(t *testing.T) {
+		low, err := tx.New(uTxOuts[:1], 1, 1, privKey, addr, dst)
+		require.NoError(t, err)
+		high, err := tx.New(uTxOuts[1:2], 1, 3, privKey, addr, dst)
+		require.NoError(t, err)
+
+		require.NoError(t, n.AddTx(ctx, low))
+		require.NoError(t, n.AddTx(ctx, high))
+
+		// only one of them fits the block.
+		defer func(size int) { blockchain.MaxBlockSize = size }(blockchain.MaxBlockSize)
+		blockchain.MaxBlockSize = high.Size()
+
+		b, err := n.NewBlockTemplate(ctx, nil)
+		require.NoError(t, err)
+		require.Len(t, b.Body.Txs, 1)
+		assert.Equal(t, high.Hash, b.Body.Txs[0].Hash)
+		assert.Equal(t, block.Reward(b.Body.Txs, 3), b.Body.CoinbaseTx.OutputSum())
+	})
+
+	t.Run("unconfirmed parent", func(t *testing.T) {
+		parent, err := tx.New(uTxOuts[2:], 4, 0, privKey, addr, addr)
+		require.NoError(t, err)
+		require.NoError(t, n.AddTx(ctx, parent))
+
+		// the child pays the higher fee rate, but should be mined after.
+		child, err := tx.New([]*tx.UTxOutput{{TxHash: parent.Hash, OutIdx: 0, Amount: 4}}, 3, 1, privKey, addr, dst)
+		require.NoError(t, err)
+		require.NoError(t, n.AddTx(ctx, child))
+
+		b, err := n.NewBlockTemplate(ctx, nil)
+		require.NoError(t, err)
+
+		order := make(map[string]int, len(b.Body.Txs))
+		for i, transaction := range b.Body.Txs {
+			order[string(transaction.Hash)] = i
+		}
+		require.Contains(t, order, string(parent.Hash))
+		require.Contains(t, order, string(child.Hash))
+		assert.Less(t, order[string(parent.Hash)], order[string(child.Hash)])
+
+		require.NoError(t, n.ReceiveBlock(ctx, mine(t, b)))
+		assert.Zero(t, n.FeeIndex.Len())
+	})
+}
+
+func TestMempoolConflicts(t *testing.T) {
+	var h hooks
+	ctx, n := newNode(t, &h)
+	privKey, addr := newWallet(t)
+	_, dst := newWallet(t)
+
+	uTxOuts := fund(t, ctx, n, privKey, addr)
+
+	toDst, err := tx.New(uTxOuts[:1], 1, 0, privKey, addr, dst)
+	require.NoError(t, err)
+	require.NoError(t, n.AddTx(ctx, toDst))
+
+	change := &tx.UTxOutput{TxHash: toDst.Hash, OutIdx: 1, Amount: uTxOuts[0].Amount - 1}
+	child, err := tx.New([]*tx.UTxOutput{change}, change.Amount, 0, privKey, addr, dst)
+	require.NoError(t, err)
+	require.NoError(t, n.AddTx(ctx, child))
+
+	toSelf, err := tx.New(uTxOuts[:1], 1, 1, privKey, addr, addr)
+	require.NoError(t, err)
+	assert.ErrorIs(t, n.AddTx(ctx, toSelf), node.ErrMempoolConflict)
+
+	// the wallet does not spend the outputs spent in the mempool.
+	unspent, _, err := n.FindUTxOutputs(ctx, addr)
+	require.NoError(t, err)
+	assert.Len(t, unspent, len(uTxOuts)-1)
+
+	// the mempool stored before conflicts were rejected.
+	require.NoError(t, storage.PutTxToMempool(ctx, toSelf))
+	n = node.New(node.Config{})
+	require.NoError(t, n.Load(ctx))
+	require.Equal(t, 3, n.FeeIndex.Len())
+
+	// only the one paying the highest fee rate is mined.
+	b, err := n.NewBlockTemplate(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, b.Body.Txs, 1)
+	assert.Equal(t, toSelf.Hash, b.Body.Txs[0].Hash)
+
+	// the others are evicted once it is mined.
+	require.NoError(t, n.ReceiveBlock(ctx, mine(t, b)))
+	assert.Zero(t, n.FeeIndex.Len())
+
+	txs, err := storage.FindAllTxsFromMempool(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, txs)
+}
@@ -0,0 +1,176 @@
+package node
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/consensus"
+	"miner/internal/hash"
+	"miner/internal/mempool"
+	"miner/internal/storage"
+	"miner/internal/tx"
+)
+
+var ErrMempoolConflict = errors.New("transaction spends an output spent by a mempool transaction")
+
+// AddTx validates the transaction, created locally or received from a peer,
+// and adds it to the mempool.
+func (n *Node) AddTx(ctx context.Context, transaction *tx.Transaction) error {
+	if err := consensus.ValidateTx(ctx, mempoolState{n}, transaction); err != nil {
+		return err
+	}
+
+	// the first transaction spending an output is kept.
+	if conflicts := n.Spends.Conflicts([]*tx.Transaction{transaction}); len(conflicts) > 0 {
+		return errors.Wrapf(ErrMempoolConflict, "%x", conflicts[0])
+	}
+
+	// the entry is made first, so that a transaction failing it is not stored.
+	entry, err := n.NewMempoolEntry(ctx, transaction)
+	if err != nil {
+		return errors.Wrap(err, "failed to calculate fee")
+	}
+
+	if err := storage.PutTxToMempool(ctx, transaction); err != nil {
+		return errors.Wrap(err, "failed to put tx to mempool")
+	}
+
+	n.FeeIndex.Add(entry)
+	n.Spends.Add(transaction)
+	n.cfg.OnMempoolChanged()
+
+	return nil
+}
+
+// loadMempool fills the fee index and the spends with the transactions
+// in the mempool.
+func (n *Node) loadMempool(ctx context.Context) error {
+	txs, err := storage.FindAllTxsFromMempool(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to find txs from mempool")
+	}
+
+	for _, tx := range txs {
+		entry, err := n.NewMempoolEntry(ctx, tx)
+		if err != nil {
+			return err
+		}
+		n.FeeIndex.Add(entry)
+		n.Spends.Add(tx)
+	}
+
+	return nil
+}
+
+// recordBlockFees records fee rates of the block's transactions to the estimator.
+// It should be called before the used outputs are deleted.
+func (n *Node) recordBlockFees(ctx context.Context, block *block.Block) error {
+	entries := make([]*mempool.Entry, 0, len(block.Body.Txs))
+	for _, tx := range block.Body.Txs {
+		if entry, ok := n.FeeIndex.Get(tx.Hash); ok {
+			entries = append(entries, entry)
+			continue
+		}
+
+		entry, err := n.NewMempoolEntry(ctx, tx)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	n.FeeEstimator.Record(mempool.NewBlockStats(entries))
+	return nil
+}
+
+// FindUTxOutputs finds the unspent outputs of pubKey, which the mempool
+// transactions do not spend either, and their sum.
+func (n *Node) FindUTxOutputs(ctx context.Context, pubKey []byte) ([]*tx.UTxOutput, uint64, error) {
+	uTxOuts, _, err := storage.FindUTxOutputs(ctx, pubKey)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to find uTxOutputs")
+	}
+
+	unspent := uTxOuts[:0]
+	var got uint64
+	for _, out := range uTxOuts {
+		if n.Spends.Spent(out.TxHash, out.OutIdx) {
+			continue
+		}
+		unspent = append(unspent, out)
+		got += out.Amount
+	}
+
+	return unspent, got, nil
+}
+
+// MempoolValue returns what the miner of every mempool transaction earns.
+func (n *Node) MempoolValue() uint64 {
+	entries := n.FeeIndex.Sorted()
+
+	value := uint64(len(entries)) * blockchain.MiningPrize
+	for _, entry := range entries {
+		value += entry.Fee
+	}
+	return value
+}
+
+// mempoolState is the chain state with the mempool transactions on top,
+// so that a transaction can spend the outputs of the unconfirmed ones.
+type mempoolState struct {
+	*Node
+}
+
+func (s mempoolState) FindTx(ctx context.Context, txHash hash.Hash) (*tx.Transaction, error) {
+	if transaction, err := storage.FindTx(ctx, txHash); err == nil {
+		return transaction, nil
+	}
+
+	txs, err := storage.FindTxsFromMempool(ctx, []hash.Hash{txHash.ToHex()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find transaction")
+	}
+	return txs[0], nil
+}
+
+// NewMempoolEntry calculates the fee of the transaction.
+// Fee is the amount of the used outputs which is not spent by the transaction's outputs.
+// The used outputs can be of the mempool transactions.
+func (n *Node) NewMempoolEntry(ctx context.Context, transaction *tx.Transaction) (*mempool.Entry, error) {
+	entry := &mempool.Entry{
+		Hash: transaction.Hash,
+		Size: transaction.Size(),
+	}
+
+	// coinbase and mint transactions create new coins, so they pay no fee.
+	if transaction.IsCoinbase() || transaction.IsMint() {
+		return entry, nil
+	}
+
+	var in, out uint64
+	for _, input := range transaction.Inputs {
+
+		prev, err := mempoolState{n}.FindTx(ctx, input.TxHash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find transaction")
+		}
+
+		if int(input.OutIdx) >= len(prev.Outputs) {
+			return nil, errors.Errorf("outIdx %d cannot be reached", input.OutIdx)
+		}
+		in += prev.Outputs[input.OutIdx].Amount
+	}
+
+	for _, output := range transaction.Outputs {
+		out += output.Amount
+	}
+
+	if in > out {
+		entry.Fee = in - out
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,171 @@
+package orphan
+
+import (
+	"sync"
+	"time"
+
+	"miner/internal/block"
+	"miner/internal/hash"
+)
+
+const (
+	DefaultLimit = 100
+	DefaultTTL   = 10 * time.Minute
+)
+
+type entry struct {
+	block   *block.Block
+	addedAt time.Time
+	seq     uint64
+}
+
+// Pool holds blocks whose parent is not known yet, so that they can be
+// connected once the parent arrives.
+type Pool struct {
+	mu       sync.Mutex
+	limit    int
+	ttl      time.Duration
+	blocks   map[string]*entry
+	byParent map[string][]string
+	seq      uint64
+
+	requestParent func(parentHash hash.Hash)
+}
+
+// NewPool creates a pool holding up to limit blocks for ttl.
+// requestParent is called with the parent hash of every newly added orphan,
+// and it may be nil.
+func NewPool(limit int, ttl time.Duration, requestParent func(parentHash hash.Hash)) *Pool {
+	return &Pool{
+		limit:         limit,
+		ttl:           ttl,
+		blocks:        make(map[string]*entry),
+		byParent:      make(map[string][]string),
+		requestParent: requestParent,
+	}
+}
+
+// Add adds the orphan block. If the pool is full, the oldest block is evicted.
+// It returns false if the block is already in the pool.
+func (p *Pool) Add(b *block.Block) bool {
+	now := time.Now()
+
+	p.mu.Lock()
+	p.expireBefore(now.Add(-p.ttl))
+
+	key := string(b.Header.CurHash)
+	if _, ok := p.blocks[key]; ok {
+		p.mu.Unlock()
+		return false
+	}
+
+	for len(p.blocks) >= p.limit && p.limit > 0 {
+		p.removeOldest()
+	}
+
+	p.seq++
+	p.blocks[key] = &entry{block: b, addedAt: now, seq: p.seq}
+
+	parent := string(b.Header.PrevHash)
+	p.byParent[parent] = append(p.byParent[parent], key)
+	p.mu.Unlock()
+
+	// the parent may also be an orphan, then it is already requested.
+	if p.requestParent != nil && !p.Has(b.Header.PrevHash) {
+		p.requestParent(b.Header.PrevHash)
+	}
+
+	return true
+}
+
+// Has reports whether the block of given hash is in the pool.
+func (p *Pool) Has(blockHash hash.Hash) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.blocks[string(blockHash)]
+	return ok
+}
+
+// Len returns the number of the orphans.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.blocks)
+}
+
+// TakeChildren removes and returns the orphans whose parent is parentHash,
+// in the order they were added.
+func (p *Pool) TakeChildren(parentHash hash.Hash) []*block.Block {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys := p.byParent[string(parentHash)]
+	delete(p.byParent, string(parentHash))
+
+	children := make([]*block.Block, 0, len(keys))
+	for _, key := range keys {
+		if e, ok := p.blocks[key]; ok {
+			delete(p.blocks, key)
+			children = append(children, e.block)
+		}
+	}
+
+	return children
+}
+
+// ExpireBefore removes orphans added before t, and returns the number of them.
+func (p *Pool) ExpireBefore(t time.Time) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.expireBefore(t)
+}
+
+func (p *Pool) expireBefore(t time.Time) (n int) {
+	for key, e := range p.blocks {
+		if e.addedAt.Before(t) {
+			p.remove(key)
+			n++
+		}
+	}
+	return
+}
+
+func (p *Pool) removeOldest() {
+	var oldestKey string
+	var oldest *entry
+	for key, e := range p.blocks {
+		if oldest == nil || e.seq < oldest.seq {
+			oldestKey, oldest = key, e
+		}
+	}
+
+	if oldest != nil {
+		p.remove(oldestKey)
+	}
+}
+
+func (p *Pool) remove(key string) {
+	e, ok := p.blocks[key]
+	if !ok {
+		return
+	}
+	delete(p.blocks, key)
+
+	parent := string(e.block.Header.PrevHash)
+	siblings := p.byParent[parent]
+	for i, sibling := range siblings {
+		if sibling == key {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+
+	if len(siblings) == 0 {
+		delete(p.byParent, parent)
+	} else {
+		p.byParent[parent] = siblings
+	}
+}
@@ -0,0 +1,61 @@
+package orphan_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"miner/internal/block"
+	"miner/internal/hash"
+	"miner/internal/orphan"
+)
+
+func newBlock(cur, prev string) *block.Block {
+	return &block.Block{Header: &block.Header{CurHash: []byte(cur), PrevHash: []byte(prev)}}
+}
+
+func TestPool(t *testing.T) {
+	var requested []hash.Hash
+	pool := orphan.NewPool(3, time.Hour, func(parentHash hash.Hash) {
+		requested = append(requested, parentHash)
+	})
+
+	assert.True(t, pool.Add(newBlock("b2", "b1")))
+	assert.True(t, pool.Add(newBlock("b3", "b2")))
+	assert.False(t, pool.Add(newBlock("b3", "b2")))
+
+	t.Run("request parent", func(t *testing.T) {
+		// b2 is already in the pool, so only b1 is requested.
+		assert.Equal(t, []hash.Hash{hash.Hash("b1")}, requested)
+	})
+
+	t.Run("connect", func(t *testing.T) {
+		children := pool.TakeChildren([]byte("b1"))
+		if assert.Len(t, children, 1) {
+			assert.Equal(t, hash.Hash("b2"), children[0].Header.CurHash)
+		}
+
+		children = pool.TakeChildren([]byte("b2"))
+		if assert.Len(t, children, 1) {
+			assert.Equal(t, hash.Hash("b3"), children[0].Header.CurHash)
+		}
+
+		assert.Zero(t, pool.Len())
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		for _, cur := range []string{"x1", "x2", "x3", "x4"} {
+			pool.Add(newBlock(cur, "unknown"))
+		}
+
+		assert.Equal(t, 3, pool.Len())
+		assert.False(t, pool.Has([]byte("x1")))
+		assert.True(t, pool.Has([]byte("x4")))
+	})
+
+	t.Run("expire", func(t *testing.T) {
+		assert.Equal(t, 3, pool.ExpireBefore(time.Now().Add(time.Second)))
+		assert.Empty(t, pool.TakeChildren([]byte("unknown")))
+	})
+}
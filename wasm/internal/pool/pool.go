@@ -0,0 +1,191 @@
+// Package pool implements share accounting of a mining pool, whose workers
+// mine the templates of the pool at a lower share difficulty, so that their
+// work can be measured before any of them finds a block.
+package pool
+
+import (
+	"bytes"
+	"math/bits"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/blocktemplate"
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/pow"
+	"miner/internal/tx"
+)
+
+var (
+	ErrLowDifficulty  = errors.New("share does not meet the share difficulty")
+	ErrDuplicateShare = errors.New("share is already submitted")
+	ErrInvalidHash    = errors.New("share hash is not valid")
+)
+
+// Template is the block template given to the workers.
+type Template struct {
+	*blocktemplate.Template
+	ShareDifficulty uint8     `json:"shareDifficulty"`
+	ShareTarget     hash.Hash `json:"shareTarget"`
+}
+
+// WorkerShares is the number of the shares of a worker in the current round.
+type WorkerShares struct {
+	Addr   hash.Hash `json:"addr"`
+	Shares uint64    `json:"shares"`
+}
+
+// Pool tracks the shares of the workers in a round, which ends when a block
+// is found. It is safe for concurrent use.
+type Pool struct {
+	mu              sync.Mutex
+	shareDifficulty uint8
+	alg             pow.Algorithm
+	shares          map[string]uint64
+	seen            map[string]struct{}
+}
+
+func New(shareDifficulty uint8, alg pow.Algorithm) *Pool {
+	return &Pool{
+		shareDifficulty: shareDifficulty,
+		alg:             alg,
+		shares:          make(map[string]uint64),
+		seen:            make(map[string]struct{}),
+	}
+}
+
+// NewTemplate creates the template of b for the workers.
+func (p *Pool) NewTemplate(b *block.Block) *Template {
+	return &Template{
+		Template:        blocktemplate.New(b, p.alg),
+		ShareDifficulty: p.shareDifficulty,
+		ShareTarget:     blocktemplate.Target(p.shareDifficulty),
+	}
+}
+
+// Submit accounts the share of worker, whose header should have its hash and
+// nonce. It reports whether the share also meets the block difficulty. Such a
+// share is not accounted, since its block pays by the shares before it: the
+// round ends with Reset once the block connects, and the worker gets no
+// credit if it does not.
+func (p *Pool) Submit(worker hash.Hash, header *block.Header) (isBlock bool, err error) {
+	if !bytes.Equal(header.CurHash, header.MakeHash()) {
+		return false, ErrInvalidHash
+	}
+
+	powHash := header.PowHash(p.alg)
+	if !util.CheckPrefix(powHash, p.shareDifficulty) {
+		return false, ErrLowDifficulty
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := string(header.CurHash)
+	if _, ok := p.seen[key]; ok {
+		return false, ErrDuplicateShare
+	}
+
+	if util.CheckPrefix(powHash, header.Difficulty) {
+		return true, nil
+	}
+
+	p.seen[key] = struct{}{}
+	p.shares[string(worker)]++
+
+	return false, nil
+}
+
+// Shares returns the shares of every worker of the round ordered by address.
+func (p *Pool) Shares() []WorkerShares {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.sharesLocked()
+}
+
+func (p *Pool) sharesLocked() []WorkerShares {
+	shares := make([]WorkerShares, 0, len(p.shares))
+	for addr, n := range p.shares {
+		shares = append(shares, WorkerShares{Addr: hash.Hash(addr), Shares: n})
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		return bytes.Compare(shares[i].Addr, shares[j].Addr) < 0
+	})
+
+	return shares
+}
+
+// Payouts splits reward to the workers in proportion to their shares. The
+// remainder of the division goes to the workers with the most shares, and
+// the whole reward goes to fallback when there is no share yet.
+func (p *Pool) Payouts(reward uint64, fallback hash.Hash) []*tx.TxOutput {
+	p.mu.Lock()
+	shares := p.sharesLocked()
+	p.mu.Unlock()
+
+	var total uint64
+	for _, s := range shares {
+		total += s.Shares
+	}
+
+	if total == 0 || reward == 0 {
+		return []*tx.TxOutput{{Addr: fallback, Amount: reward}}
+	}
+
+	outputs := make([]*tx.TxOutput, len(shares))
+	var paid uint64
+	for i, s := range shares {
+		// reward*shares can overflow, so it is divided in two steps, and the
+		// product of the remainder, below total*total, is taken in 128 bits.
+		hi, lo := bits.Mul64(reward%total, s.Shares)
+		rem, _ := bits.Div64(hi, lo, total)
+		amount := reward/total*s.Shares + rem
+		outputs[i] = &tx.TxOutput{Addr: s.Addr, Amount: amount}
+		paid += amount
+	}
+
+	byShares := make([]int, len(shares))
+	for i := range byShares {
+		byShares[i] = i
+	}
+	sort.SliceStable(byShares, func(i, j int) bool {
+		return shares[byShares[i]].Shares > shares[byShares[j]].Shares
+	})
+
+	for i := 0; paid < reward; i = (i + 1) % len(byShares) {
+		outputs[byShares[i]].Amount++
+		paid++
+	}
+
+	// outputs without amount are not worth storing.
+	nonEmpty := outputs[:0]
+	for _, out := range outputs {
+		if out.Amount > 0 {
+			nonEmpty = append(nonEmpty, out)
+		}
+	}
+
+	return nonEmpty
+}
+
+// NewBlock creates the block of txs on prevHash whose coinbase splits reward
+// by the current shares. The proof of work commits to the coinbase, so the
+// split cannot change once the block is mined, and the block should be
+// created again whenever a share is accepted.
+func (p *Pool) NewBlock(txs []*tx.Transaction, reward uint64, fallback, prevHash hash.Hash, difficulty uint8) (*block.Block, error) {
+	return block.NewWithCoinbase(p.Payouts(reward, fallback), txs, prevHash, difficulty)
+}
+
+// Reset starts a new round once a block is found.
+func (p *Pool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.shares = make(map[string]uint64)
+	p.seen = make(map[string]struct{})
+}
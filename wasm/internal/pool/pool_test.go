@@ -0,0 +1,166 @@
+package pool_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/hash"
+	"miner/internal/misc/util"
+	"miner/internal/pool"
+	"miner/internal/pow"
+	"miner/internal/tx"
+	"miner/internal/versionbits"
+)
+
+// mineShare finds a header after start which meets shareDifficulty.
+func mineShare(header *block.Header, start uint32, shareDifficulty uint8) *block.Header {
+	h := *header
+	for h.Nonce = start; !util.CheckPrefix(h.MakeHash(), shareDifficulty); h.Nonce++ {
+	}
+	h.CurHash = h.MakeHash()
+	return &h
+}
+
+func TestPool(t *testing.T) {
+	p := pool.New(4, pow.SHA256)
+	alice, bob := hash.Hash("alice"), hash.Hash("bob")
+
+	header := &block.Header{
+		Version:    versionbits.TopBits,
+		PrevHash:   []byte{0x00},
+		DataHash:   []byte{0x11},
+		Timestamp:  time.Unix(0, 1700000000000000000),
+		Difficulty: 32,
+	}
+
+	t.Run("accept shares", func(t *testing.T) {
+		var nonce uint32
+		for i, worker := range []hash.Hash{alice, alice, alice, bob} {
+			share := mineShare(header, nonce, 4)
+			nonce = share.Nonce + 1
+
+			isBlock, err := p.Submit(worker, share)
+			require.NoError(t, err, "share %d", i)
+			assert.False(t, isBlock)
+		}
+
+		assert.Equal(t, []pool.WorkerShares{{Addr: alice, Shares: 3}, {Addr: bob, Shares: 1}}, p.Shares())
+	})
+
+	t.Run("reject shares", func(t *testing.T) {
+		share := mineShare(header, 0, 4)
+		_, err := p.Submit(bob, share)
+		assert.ErrorIs(t, err, pool.ErrDuplicateShare)
+
+		low := *header
+		for low.Nonce = 0; util.CheckPrefix(low.MakeHash(), 4); low.Nonce++ {
+		}
+		low.CurHash = low.MakeHash()
+		_, err = p.Submit(bob, &low)
+		assert.ErrorIs(t, err, pool.ErrLowDifficulty)
+
+		fake := *share
+		fake.Nonce++
+		_, err = p.Submit(bob, &fake)
+		assert.ErrorIs(t, err, pool.ErrInvalidHash)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		easy := *header
+		easy.Difficulty = 4
+
+		share := mineShare(&easy, 0, 4)
+		isBlock, err := p.Submit(bob, share)
+		require.NoError(t, err)
+		assert.True(t, isBlock)
+
+		// the block is not credited until it connects, so it can be
+		// submitted again if it does not.
+		assert.Equal(t, []pool.WorkerShares{{Addr: alice, Shares: 3}, {Addr: bob, Shares: 1}}, p.Shares())
+		isBlock, err = p.Submit(bob, share)
+		require.NoError(t, err)
+		assert.True(t, isBlock)
+	})
+
+	t.Run("payouts", func(t *testing.T) {
+		// alice has 3 shares and bob has 1.
+		assert.Equal(t, []*tx.TxOutput{
+			{Addr: alice, Amount: 9},
+			{Addr: bob, Amount: 2},
+		}, p.Payouts(11, []byte("pool")))
+
+		p.Reset()
+		assert.Empty(t, p.Shares())
+		assert.Equal(t, []*tx.TxOutput{{Addr: []byte("pool"), Amount: 11}}, p.Payouts(11, []byte("pool")))
+	})
+}
+
+func TestPayoutsSum(t *testing.T) {
+	p := pool.New(0, pow.SHA256)
+
+	header := &block.Header{Version: versionbits.TopBits, Timestamp: time.Unix(0, 0)}
+	for i := 0; i < 7; i++ {
+		share := *header
+		share.Nonce = uint32(i)
+		share.CurHash = share.MakeHash()
+
+		_, err := p.Submit(hash.Hash{byte(i % 3)}, &share)
+		require.NoError(t, err)
+	}
+
+	for _, reward := range []uint64{1, 2, 10, 100, 12345} {
+		var sum uint64
+		for _, out := range p.Payouts(reward, nil) {
+			sum += out.Amount
+		}
+		assert.Equal(t, reward, sum)
+	}
+}
+
+func TestNewBlock(t *testing.T) {
+	p := pool.New(2, pow.SHA256)
+	alice, bob := hash.Hash("alice"), hash.Hash("bob")
+
+	newBlock := func() *block.Block {
+		b, err := p.NewBlock(nil, 10, []byte("pool"), []byte("prev"), 8)
+		require.NoError(t, err)
+		return b
+	}
+
+	b := newBlock()
+	assert.Equal(t, []*tx.TxOutput{{Addr: []byte("pool"), Amount: 10}}, b.Body.CoinbaseTx.Outputs)
+
+	// every accepted share moves the workers to the block created after it.
+	var nonce uint32
+	for i, worker := range []hash.Hash{alice, alice, bob} {
+		share := *b.Header
+		for share.Nonce = nonce; ; share.Nonce++ {
+			h := share.MakeHash()
+			if util.CheckPrefix(h, 2) && !util.CheckPrefix(h, 8) {
+				break
+			}
+		}
+		share.CurHash = share.MakeHash()
+		nonce = share.Nonce + 1
+
+		isBlock, err := p.Submit(worker, &share)
+		require.NoError(t, err, "share %d", i)
+		require.False(t, isBlock)
+
+		b = newBlock()
+	}
+
+	isBlock, err := p.Submit(bob, mineShare(b.Header, nonce, 8))
+	require.NoError(t, err)
+	require.True(t, isBlock)
+
+	// the winning block pays by the shares submitted before it.
+	assert.Equal(t, []*tx.TxOutput{
+		{Addr: alice, Amount: 7},
+		{Addr: bob, Amount: 3},
+	}, b.Body.CoinbaseTx.Outputs)
+}
@@ -0,0 +1,95 @@
+// Package pow implements the proof-of-work algorithms a chain can use. The
+// algorithm hashes the encoded block header, and the header is valid when the
+// hash meets the difficulty of the chain.
+package pow
+
+import (
+	"crypto/sha256"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown proof-of-work algorithm")
+
+// Algorithm hashes the encoded block header for the proof of work.
+// It is safe for concurrent use.
+type Algorithm interface {
+	// Name is the name the chain parameters select the algorithm by.
+	Name() string
+	// Hash returns the proof-of-work hash of the encoded header.
+	Hash(header []byte) []byte
+}
+
+const (
+	NameSHA256       = "sha256"
+	NameDoubleSHA256 = "sha256d"
+	NameScrypt       = "scrypt"
+)
+
+var (
+	// SHA256 is the single SHA-256 of the header, which is also the hash
+	// identifying the block.
+	SHA256 Algorithm = sha256Algorithm{}
+	// DoubleSHA256 is SHA-256 applied twice, as in Bitcoin.
+	DoubleSHA256 Algorithm = doubleSHA256Algorithm{}
+	// DefaultScrypt is scrypt with the parameters of Litecoin, which need
+	// 128KiB of memory per hash, so that browsers can still mine it.
+	DefaultScrypt Algorithm = Scrypt{N: 1024, R: 1, P: 1}
+)
+
+// Lookup returns the algorithm of name.
+func Lookup(name string) (Algorithm, error) {
+	switch name {
+	case NameSHA256:
+		return SHA256, nil
+	case NameDoubleSHA256:
+		return DoubleSHA256, nil
+	case NameScrypt:
+		return DefaultScrypt, nil
+	}
+
+	return nil, errors.Wrapf(ErrUnknownAlgorithm, "%q", name)
+}
+
+type sha256Algorithm struct{}
+
+func (sha256Algorithm) Name() string {
+	return NameSHA256
+}
+
+func (sha256Algorithm) Hash(header []byte) []byte {
+	sum := sha256.Sum256(header)
+	return sum[:]
+}
+
+type doubleSHA256Algorithm struct{}
+
+func (doubleSHA256Algorithm) Name() string {
+	return NameDoubleSHA256
+}
+
+func (doubleSHA256Algorithm) Hash(header []byte) []byte {
+	first := sha256.Sum256(header)
+	sum := sha256.Sum256(first[:])
+	return sum[:]
+}
+
+// Scrypt is the memory-hard scrypt with the header as both the password and
+// the salt. It needs 128*N*R bytes of memory per hash.
+type Scrypt struct {
+	N, R, P int
+}
+
+func (Scrypt) Name() string {
+	return NameScrypt
+}
+
+func (s Scrypt) Hash(header []byte) []byte {
+	sum, err := scrypt.Key(header, header, s.N, s.R, s.P, sha256.Size)
+	if err != nil {
+		// only invalid parameters fail, which is a programming error.
+		panic(errors.Wrap(err, "invalid scrypt parameters"))
+	}
+	return sum
+}
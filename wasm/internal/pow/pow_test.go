@@ -0,0 +1,40 @@
+package pow_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/pow"
+)
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		alg  pow.Algorithm
+		want string
+	}{
+		{pow.SHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{pow.DoubleSHA256, "5df6e0e2761359d30a8275058e299fcc0381534545f55cf43e41983f5d4c9456"},
+		// the first half of the RFC 7914 test vector.
+		{pow.Scrypt{N: 16, R: 1, P: 1}, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg.Name(), func(t *testing.T) {
+			assert.Equal(t, tt.want, hex.EncodeToString(tt.alg.Hash(nil)))
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, alg := range []pow.Algorithm{pow.SHA256, pow.DoubleSHA256, pow.DefaultScrypt} {
+		found, err := pow.Lookup(alg.Name())
+		require.NoError(t, err)
+		assert.Equal(t, alg, found)
+	}
+
+	_, err := pow.Lookup("x11")
+	assert.ErrorIs(t, err, pow.ErrUnknownAlgorithm)
+}
@@ -2,147 +2,177 @@ package processor
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/binary"
+	"math"
 	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
 	"miner/internal/misc/util"
+	"miner/internal/pow"
 )
 
-const cpuBatchSize = 5000
-
-type Result struct {
-	Hash  []byte
-	Nonce uint64
+// DefaultCPUBatchSize is the number of the nonces a worker claims at once.
+const DefaultCPUBatchSize = 5000
+
+// cancelCheckInterval is the number of the hashes between cancellation checks,
+// where a worker also yields to the other goroutines.
+const cancelCheckInterval = 1024
+
+// nonceSpace is the number of the uint32 nonces.
+const nonceSpace = math.MaxUint32 + 1
+
+// CPUMiner is the Miner which runs a fixed pool of workers on the CPU.
+// The workers claim ranges of nonces from a shared counter until one of them
+// finds a solution, the nonces are used up or the context is done.
+type CPUMiner struct {
+	// Workers is the number of the workers. GOMAXPROCS if zero.
+	Workers int
+	// BatchSize is the number of the nonces a worker claims at once.
+	// DefaultCPUBatchSize if zero.
+	BatchSize uint32
+	// Algorithm is the proof-of-work algorithm. pow.SHA256 if nil.
+	Algorithm pow.Algorithm
+	// Throttle limits the workers while they run. Unlimited if nil.
+	Throttle *Throttle
 }
 
-// TODO: this should be refactored.
-func FindNonceUsingCPU(ctx context.Context, data []byte, difficulty uint8) (procCnt uint32, _ <-chan []byte, _ <-chan Result) {
-	procCnt = uint32(runtime.GOMAXPROCS(0))
-
-	candidateStream := make(chan []byte)
-	result := make(chan Result)
-
-	ctx, cancel := context.WithCancel(ctx)
-	go func() {
-		finished := make(chan struct{})
+func (m CPUMiner) Mine(ctx context.Context, in []byte, difficulty uint8, opts Options) (Result, error) {
+	workers := m.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
 
-		defer close(candidateStream)
-		defer close(result)
-		defer close(finished)
+	batchSize := uint64(m.BatchSize)
+	if batchSize == 0 {
+		batchSize = DefaultCPUBatchSize
+	}
 
-		var nonce uint64
-		for i := uint32(0); i < procCnt; i++ {
-			go makeRunner(ctx, data, nonce, difficulty, cancel, candidateStream, result, finished)
-			nonce += cpuBatchSize
-		}
+	alg := m.Algorithm
+	if alg == nil {
+		alg = pow.SHA256
+	}
 
-		for {
-			select {
-			case r := <-result:
-				result <- r
-				return
-			case <-finished:
-				go makeRunner(ctx, data, nonce, difficulty, cancel, candidateStream, result, finished)
-				nonce += cpuBatchSize
-			}
-		}
-	}()
+	search := &cpuSearch{
+		in:         in,
+		alg:        alg,
+		difficulty: difficulty,
+		batchSize:  batchSize,
+		telemetry:  opts.Telemetry,
+		throttle:   m.Throttle,
+		exhausted:  make(chan struct{}),
+		// the first solution is kept here, and the others are dropped.
+		result: make(chan Result, 1),
+	}
 
-	return procCnt, candidateStream, result
-}
+	search.next.Store(uint64(opts.StartNonce))
 
-func makeRunner(ctx context.Context, data []byte, nonce uint64, diff uint8, cancel func(), candidateStream chan<- []byte, result chan<- Result, finished chan<- struct{}) {
-	done := make(chan struct{})
-	defer close(done)
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	candStream, res := findUsingCPU(data, nonce, diff, done)
-	for {
-		select {
-		case <-ctx.Done():
-			done <- struct{}{}
-			return
-		case candidate := <-candStream:
-			select {
-			case <-ctx.Done():
-				done <- struct{}{}
-				return
-			case candidateStream <- candidate:
-			default:
-			}
-		case r := <-res:
-			select {
-			case <-ctx.Done():
-				done <- struct{}{}
-				return
-			default:
-			}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			search.work(workerCtx, cancel, worker)
+		}(i)
+	}
+	wg.Wait()
 
-			if r.Hash == nil {
-				finished <- struct{}{}
-				return
-			}
-			cancel()
-			result <- r
+	// a solution found before cancellation is still returned.
+	select {
+	case r := <-search.result:
+		return r, nil
+	default:
+	}
 
-		}
+	if err := ctx.Err(); err != nil {
+		return Result{}, errors.Wrap(ErrCancelled, err.Error())
 	}
+	return Result{}, ErrNonceNotFound
 }
 
-func findUsingCPU(data []byte, nonce uint64, difficulty uint8, done <-chan struct{}) (_ <-chan []byte, _ <-chan Result) {
-	limit := nonce + cpuBatchSize
+// cpuSearch is the state shared by the workers of a search.
+type cpuSearch struct {
+	in         []byte
+	alg        pow.Algorithm
+	difficulty uint8
+	batchSize  uint64
+	telemetry  *Telemetry
+	throttle   *Throttle
+
+	next   atomic.Uint64
+	result chan Result
+	// exhausted is closed once the nonces are used up, so that the parked
+	// workers stop.
+	exhausted     chan struct{}
+	exhaustedOnce sync.Once
+}
 
-	candidateStream := make(chan []byte)
-	result := make(chan Result)
+// work searches claimed ranges until the search stops. The worker is parked
+// between the ranges while the throttle does not allow it to hash, so that no
+// range is held by a parked worker.
+func (s *cpuSearch) work(ctx context.Context, stop func(), worker int) {
+	hash := newNonceHash(s.alg, s.in)
+	busySince := time.Now()
+
+	for s.throttle.park(ctx, worker, &busySince, s.exhausted) {
+		start := s.next.Add(s.batchSize) - s.batchSize
+		if start >= nonceSpace {
+			s.exhaustedOnce.Do(func() { close(s.exhausted) })
+			return
+		}
 
-	go func() {
-		defer close(candidateStream)
-		defer close(result)
+		end := start + s.batchSize
+		if end > nonceSpace {
+			end = nonceSpace
+		}
 
-		hash := sha256.New()
-		for nonce < limit {
-			if _, err := hash.Write(data); err != nil {
-				panic(err)
+		for nonce := start; nonce < end; nonce++ {
+			if (nonce-start)%cancelCheckInterval == 0 {
+				// without preemption(e.g. js/wasm), other goroutines
+				// would never run while the worker hashes.
+				runtime.Gosched()
+
+				if !s.throttle.pause(ctx, &busySince) {
+					s.telemetry.Add(nonce-start, uint32(start), uint32(nonce))
+					return
+				}
 			}
 
-			if err := binary.Write(hash, binary.LittleEndian, nonce); err != nil {
-				panic(err)
+			sum := hash(uint32(nonce))
+			if !util.CheckPrefix(sum, s.difficulty) {
+				continue
 			}
 
-			sum := hash.Sum(nil)
-			ok := util.CheckPrefix(sum, difficulty)
+			s.telemetry.Add(nonce-start+1, uint32(start), uint32(nonce))
 
 			select {
-			case <-done:
-				return
+			case s.result <- Result{Hash: append([]byte{}, sum...), Nonce: uint32(nonce)}:
 			default:
 			}
-
-			if nonce%100 == 0 {
-				select {
-				case <-done:
-					return
-				case candidateStream <- sum:
-				}
-			}
-
-			if ok {
-				select {
-				case <-done:
-				case result <- Result{
-					Hash:  sum,
-					Nonce: nonce,
-				}:
-					<-done
-				}
-				return
-			}
-			hash.Reset()
-			nonce++
+			stop()
+			return
 		}
 
-		result <- Result{}
-	}()
+		s.telemetry.Add(end-start, uint32(start), uint32(end-1))
+	}
+}
 
-	return candidateStream, result
+// newNonceHash returns the function hashing the header, whose hash input is
+// in, with a nonce by alg. Its result is only valid until the next call.
+func newNonceHash(alg pow.Algorithm, in []byte) func(nonce uint32) []byte {
+	if alg == pow.SHA256 {
+		return block.NewNonceHasher(in).Hash
+	}
+
+	header := append([]byte{}, in...)
+	return func(nonce uint32) []byte {
+		header = block.AppendNonce(header[:len(in)], nonce)
+		return alg.Hash(header)
+	}
 }
@@ -3,11 +3,15 @@ package processor_test
 import (
 	"context"
 	"testing"
+	"time"
 
+	"miner/internal/block"
 	"miner/internal/misc/util"
 	"miner/internal/processor"
+	"miner/internal/versionbits"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 )
 
@@ -15,19 +19,68 @@ func TestFindNonce(t *testing.T) {
 	defer goleak.VerifyNone(t)
 
 	b := []byte{0x11, 0x53, 0x42, 0xFF, 0xEA}
-	_, candidateStream, result := processor.FindNonceUsingCPU(context.Background(), b, 12)
-
-	for {
-		select {
-		case candidate := <-candidateStream:
-			t.Logf("%x", candidate)
-		case r := <-result:
-			assert.True(t, util.CheckPrefix(r.Hash, 8))
-
-			t.Logf("%x", r.Hash)
-			t.Log(r.Nonce)
-			return
-		}
+	r, err := processor.CPUMiner{}.Mine(context.Background(), b, 12, processor.Options{})
+	require.NoError(t, err)
+
+	assert.True(t, util.CheckPrefix(r.Hash, 8))
+
+	t.Logf("%x", r.Hash)
+	t.Log(r.Nonce)
+}
+
+func TestFindNonceValidatesThroughHeader(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	header := &block.Header{
+		Version:    versionbits.TopBits,
+		PrevHash:   []byte{0x00},
+		DataHash:   []byte{0x11, 0x22},
+		Timestamp:  time.Unix(0, 1700000000000000000),
+		Difficulty: 12,
+	}
+
+	r, err := processor.CPUMiner{}.Mine(context.Background(), header.MakeHashInput(), header.Difficulty, processor.Options{})
+	require.NoError(t, err)
+
+	header.Nonce = r.Nonce
+	assert.Equal(t, r.Hash, header.MakeHash())
+	assert.True(t, util.CheckPrefix(header.MakeHash(), header.Difficulty))
+}
+
+func TestCPUMinerFindsFirstSolution(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	in := []byte{0x11, 0x53, 0x42, 0xFF, 0xEA}
+	const difficulty = 10
+
+	var first uint32
+	for !util.CheckPrefix(block.HashWithNonce(in, first), difficulty) {
+		first++
 	}
 
+	// a single worker searches the nonces in order whatever the batch size is.
+	for _, batchSize := range []uint32{1, 7, 0} {
+		r, err := processor.CPUMiner{Workers: 1, BatchSize: batchSize}.Mine(context.Background(), in, difficulty, processor.Options{})
+		require.NoError(t, err)
+		assert.Equal(t, first, r.Nonce, "batch size %d", batchSize)
+	}
+}
+
+func TestCPUMinerSolutionUnderCancellation(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	in := []byte{0x11, 0x53}
+
+	// every hash is a solution, so cancellation races with finding one.
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancel()
+
+		r, err := processor.CPUMiner{BatchSize: 1}.Mine(ctx, in, 0, processor.Options{})
+		if err != nil {
+			assert.ErrorIs(t, err, processor.ErrCancelled)
+			continue
+		}
+		assert.Equal(t, block.HashWithNonce(in, r.Nonce), r.Hash)
+	}
 }
@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"math/bits"
+	"runtime"
+)
+
+// Emulator is the KernelDevice which runs process.wgsl on the CPU with the
+// same semantics, so that the host of the kernel can be tested without a GPU.
+// The invocations of a dispatch run one after another in the order of their
+// offsets, so the first solution of a dispatch is the one reported. Dispatches
+// run when they are waited for.
+type Emulator struct {
+	inputData []uint32
+	inputSize uint32
+	prefixLen uint32
+	width     uint32
+	batchSize uint32
+
+	slots []emulatorSlot
+}
+
+// emulatorSlot is the start and result buffers of a slot.
+type emulatorSlot struct {
+	start  uint32
+	result uint32
+}
+
+func NewEmulator() *Emulator {
+	return &Emulator{}
+}
+
+func (e *Emulator) Upload(inputData []uint32, inputSize, prefixLen uint32, cfg KernelConfig) {
+	e.inputData = append([]uint32{}, inputData...)
+	e.inputSize = inputSize
+	e.prefixLen = prefixLen
+	e.width = cfg.Width()
+	e.batchSize = cfg.WithDefaults().BatchSize
+	e.slots = make([]emulatorSlot, cfg.WithDefaults().Depth)
+}
+
+func (e *Emulator) Submit(slot int, start uint32) {
+	e.slots[slot].start = start
+}
+
+func (e *Emulator) Wait(slot int) uint32 {
+	s := &e.slots[slot]
+
+	// a GPU miner waits for the dispatch in the event loop, while the
+	// emulator hashes on the goroutine. Without preemption(e.g. js/wasm),
+	// the timers of the search would never fire without yielding.
+	runtime.Gosched()
+
+	for y := uint32(0); y < workgroupSizeY; y++ {
+		for x := uint32(0); x < e.width; x++ {
+			e.invoke(s, x, y)
+		}
+	}
+
+	return s.result
+}
+
+func (e *Emulator) Destroy() {
+	e.inputData = nil
+	e.slots = nil
+}
+
+// invoke runs main of the kernel on the slot for global_invocation_id (x, y, 0).
+func (e *Emulator) invoke(s *emulatorSlot, x, y uint32) {
+	if s.result != 0 {
+		return
+	}
+
+	nonce := s.start + (x+y*e.width)*e.batchSize
+
+	var inputCopy [kernelInputCap]uint32
+	copy(inputCopy[:], e.inputData)
+
+	last := len(e.inputData)
+
+	for i := uint32(0); i < e.batchSize; i++ {
+		if s.result != 0 {
+			break
+		}
+
+		inputCopy[last] = nonce & 0xff
+		inputCopy[last+1] = (nonce >> 8) & 0xff
+		inputCopy[last+2] = (nonce >> 16) & 0xff
+		inputCopy[last+3] = (nonce >> 24) & 0xff
+
+		buf := kernelSHA256(&inputCopy, e.inputSize)
+		if s.result == 0 && kernelCheckPrefix(&buf, e.prefixLen) {
+			s.result = nonce
+		}
+
+		nonce++
+	}
+}
+
+// kernelSHA256 is sha256 of the kernel, which hashes the first size values of
+// input as bytes and returns the digest a byte per u32.
+func kernelSHA256(input *[kernelInputCap]uint32, size uint32) (hash [32]uint32) {
+	var (
+		data   [64]uint32
+		state  = [8]uint32{0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19}
+		bitlen [2]uint32
+	)
+
+	var datalen uint32
+	for i := uint32(0); i < size; i++ {
+		data[datalen] = input[i]
+		datalen++
+		if datalen == 64 {
+			kernelSHA256Transform(&state, &data)
+			if bitlen[0] > 0xffffffff-512 {
+				bitlen[1]++
+			}
+			bitlen[0] += 512
+			datalen = 0
+		}
+	}
+
+	i := datalen
+	data[i] = 0x80
+	i++
+	if datalen < 56 {
+		for ; i < 56; i++ {
+			data[i] = 0
+		}
+	} else {
+		for ; i < 64; i++ {
+			data[i] = 0
+		}
+		kernelSHA256Transform(&state, &data)
+		for j := 0; j < 56; j++ {
+			data[j] = 0
+		}
+	}
+
+	if bitlen[0] > 0xffffffff-datalen*8 {
+		bitlen[1]++
+	}
+	bitlen[0] += datalen * 8
+
+	data[63] = bitlen[0]
+	data[62] = bitlen[0] >> 8
+	data[61] = bitlen[0] >> 16
+	data[60] = bitlen[0] >> 24
+	data[59] = bitlen[1]
+	data[58] = bitlen[1] >> 8
+	data[57] = bitlen[1] >> 16
+	data[56] = bitlen[1] >> 24
+	kernelSHA256Transform(&state, &data)
+
+	for i := 0; i < 4; i++ {
+		shift := 24 - i*8
+		for j := 0; j < 8; j++ {
+			hash[i+j*4] = (state[j] >> shift) & 0xff
+		}
+	}
+
+	return hash
+}
+
+var kernelK = [64]uint32{
+	0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+	0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+	0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+	0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+	0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+	0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+	0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+	0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+}
+
+// kernelSHA256Transform is sha256_transform of the kernel, whose block holds
+// a byte per u32. Like the kernel, a value above 0xff spills into the
+// neighbouring bytes of its word.
+func kernelSHA256Transform(state *[8]uint32, data *[64]uint32) {
+	var m [64]uint32
+	for i, j := 0, 0; i < 16; i, j = i+1, j+4 {
+		m[i] = data[j]<<24 | data[j+1]<<16 | data[j+2]<<8 | data[j+3]
+	}
+	for i := 16; i < 64; i++ {
+		m[i] = sig1(m[i-2]) + m[i-7] + sig0(m[i-15]) + m[i-16]
+	}
+
+	a, b, c, d, e, f, g, h := state[0], state[1], state[2], state[3], state[4], state[5], state[6], state[7]
+	for i := 0; i < 64; i++ {
+		t1 := h + ep1(e) + (e&f ^ ^e&g) + kernelK[i] + m[i]
+		t2 := ep0(a) + (a&b ^ a&c ^ b&c)
+		h, g, f, e, d, c, b, a = g, f, e, d+t1, c, b, a, t1+t2
+	}
+
+	state[0] += a
+	state[1] += b
+	state[2] += c
+	state[3] += d
+	state[4] += e
+	state[5] += f
+	state[6] += g
+	state[7] += h
+}
+
+func ep0(x uint32) uint32 {
+	return bits.RotateLeft32(x, -2) ^ bits.RotateLeft32(x, -13) ^ bits.RotateLeft32(x, -22)
+}
+
+func ep1(x uint32) uint32 {
+	return bits.RotateLeft32(x, -6) ^ bits.RotateLeft32(x, -11) ^ bits.RotateLeft32(x, -25)
+}
+
+func sig0(x uint32) uint32 {
+	return bits.RotateLeft32(x, -7) ^ bits.RotateLeft32(x, -18) ^ x>>3
+}
+
+func sig1(x uint32) uint32 {
+	return bits.RotateLeft32(x, -17) ^ bits.RotateLeft32(x, -19) ^ x>>10
+}
+
+// kernelCheckPrefix is checkPrefix of the kernel, the same as util.CheckPrefix
+// on the digest a byte per u32.
+func kernelCheckPrefix(buf *[32]uint32, prefixLen uint32) bool {
+	prefix := prefixLen
+	for i := 0; i < len(buf); i++ {
+		if prefix == 0 {
+			break
+		}
+
+		p := prefix
+		if p > 8 {
+			p = 8
+		}
+
+		mask := uint32(1)<<(8-p) - 1
+		if mask|buf[i] != mask {
+			return false
+		}
+
+		prefix -= p
+	}
+
+	return true
+}
@@ -1,7 +1,8 @@
+//go:build js && wasm
+
 package processor
 
 import (
-	"context"
 	_ "embed"
 	"syscall/js"
 
@@ -9,20 +10,35 @@ import (
 	"github.com/mokiat/wasmgpu"
 )
 
-const (
-	x = 8
-	y = 8
-
-	cntPerCore   = 100
-	gpuBatchSize = x * y * cntPerCore
-)
-
 //go:embed process.wgsl
 var code string
 
-func FindNonceUsingGPU(ctx context.Context, data []byte, difficulty uint8) (_ chan uint32) {
-	dataUint32 := bytesToUint32Arr(data)
+// webGPUDevice is the KernelDevice of WebGPU.
+type webGPUDevice struct {
+	device   wasmgpu.GPUDevice
+	pipeline wasmgpu.GPUComputePipeline
+	cfg      KernelConfig
+
+	inputBuf     wasmgpu.GPUBuffer
+	inputSizeBuf wasmgpu.GPUBuffer
+	prefixBuf    wasmgpu.GPUBuffer
+	paramsBuf    wasmgpu.GPUBuffer
+	slots        []webGPUSlot
+	uploaded     bool
+}
+
+// webGPUSlot is the buffers of a dispatch in flight.
+type webGPUSlot struct {
+	// stagingBuf is where the start is written to before the dispatch.
+	stagingBuf wasmgpu.GPUBuffer
+	startBuf   wasmgpu.GPUBuffer
+	resultBuf  wasmgpu.GPUBuffer
+	// readBuf is where the result is copied to after the dispatch.
+	readBuf   wasmgpu.GPUBuffer
+	bindGroup wasmgpu.GPUBindGroup
+}
 
+func newWebGPUDevice() KernelDevice {
 	device := wasmgpu.NewDevice(js.Global().Call("getDevice"))
 	shaderModule := device.CreateShaderModule(wasmgpu.GPUShaderModuleDescriptor{Code: code})
 
@@ -33,173 +49,177 @@ func FindNonceUsingGPU(ctx context.Context, data []byte, difficulty uint8) (_ ch
 		},
 	})
 
-	inputBuf := device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
-		Size:             wasmgpu.GPUSize64(len(data) * 4),
-		Usage:            wasmgpu.GPUBufferUsageFlagsStorage,
-		MappedAtCreation: opt.V(true),
-	})
-	uint32Array(inputBuf.GetMappedRange(0, 0)).Call("set", dataUint32)
-	inputBuf.Unmap()
+	return &webGPUDevice{device: device, pipeline: pipeline}
+}
 
-	prefixBuf := device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
-		Size:             wasmgpu.GPUSize64(4),
-		Usage:            wasmgpu.GPUBufferUsageFlagsStorage,
-		MappedAtCreation: opt.V(true),
-	})
-	uint32Array(prefixBuf.GetMappedRange(0, 0)).Call("set", []interface{}{uint32(difficulty)})
-	prefixBuf.Unmap()
+func (d *webGPUDevice) Upload(inputData []uint32, inputSize, prefixLen uint32, cfg KernelConfig) {
+	d.cfg = cfg.WithDefaults()
 
-	inputSizeBuf := device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
-		Size:             wasmgpu.GPUSize64(4),
-		Usage:            wasmgpu.GPUBufferUsageFlagsStorage,
-		MappedAtCreation: opt.V(true),
-	})
-	uint32Array(inputSizeBuf.GetMappedRange(0, 0)).Call("set", []interface{}{uint32(len(data) + 4)})
-	inputSizeBuf.Unmap()
+	d.inputBuf = d.createBuffer(wasmgpu.GPUBufferUsageFlagsStorage, inputData...)
+	d.inputSizeBuf = d.createBuffer(wasmgpu.GPUBufferUsageFlagsStorage, inputSize)
+	d.prefixBuf = d.createBuffer(wasmgpu.GPUBufferUsageFlagsStorage, prefixLen)
+	d.paramsBuf = d.createBuffer(wasmgpu.GPUBufferUsageFlagsUniform, d.cfg.Width(), d.cfg.BatchSize)
 
-	resultBuf := device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
-		Size:             wasmgpu.GPUSize64(4),
-		Usage:            wasmgpu.GPUBufferUsageFlagsStorage | wasmgpu.GPUBufferUsageFlagsCopySrc,
-		MappedAtCreation: opt.V(true),
+	d.slots = make([]webGPUSlot, d.cfg.Depth)
+	for i := range d.slots {
+		d.slots[i] = d.createSlot()
+	}
+	d.uploaded = true
+}
+
+func (d *webGPUDevice) createSlot() webGPUSlot {
+	var slot webGPUSlot
+
+	slot.stagingBuf = d.device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
+		Size:  wasmgpu.GPUSize64(4),
+		Usage: wasmgpu.GPUBufferUsageFlagsMapWrite | wasmgpu.GPUBufferUsageFlagsCopySrc,
 	})
-	resultBuf.Unmap()
 
-	startBuf := device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
+	slot.startBuf = d.device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
 		Size:  wasmgpu.GPUSize64(4),
 		Usage: wasmgpu.GPUBufferUsageFlagsStorage | wasmgpu.GPUBufferUsageFlagsCopyDst,
 	})
 
-	startInputBuf := device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
+	// buffers are zeroed on creation, which is the not found result.
+	slot.resultBuf = d.device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
 		Size:  wasmgpu.GPUSize64(4),
-		Usage: wasmgpu.GPUBufferUsageFlagsMapWrite | wasmgpu.GPUBufferUsageFlagsCopySrc,
+		Usage: wasmgpu.GPUBufferUsageFlagsStorage | wasmgpu.GPUBufferUsageFlagsCopySrc,
 	})
 
-	tmpBuf := device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
+	slot.readBuf = d.device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
 		Size:  wasmgpu.GPUSize64(4),
 		Usage: wasmgpu.GPUBufferUsageFlagsCopyDst | wasmgpu.GPUBufferUsageFlagsMapRead,
 	})
 
-	result := make(chan uint32, 1)
-
-	go func() {
-		defer close(result)
-
-		defer inputBuf.Destroy()
-		defer prefixBuf.Destroy()
-		defer inputSizeBuf.Destroy()
-		defer resultBuf.Destroy()
-		defer tmpBuf.Destroy()
-
-		start, n := uint32(0), uint32(0)
-		for n == 0 {
-			mapBuffer(startInputBuf, wasmgpu.GPUMapModeFlagsWrite, 4, func(arr js.Value) {
-				arr.Call("set", []interface{}{start})
-			})
-
-			copyCmd := createCopyCmd(device, startInputBuf, startBuf, 4)
-			device.Queue().Submit([]wasmgpu.GPUCommandBuffer{copyCmd})
-
-			bindGroup := device.CreateBindGroup(wasmgpu.GPUBindGroupDescriptor{
-				Layout: pipeline.GetBindGroupLayout(0),
-				Entries: []wasmgpu.GPUBindGroupEntry{
-					{
-						Binding:  0,
-						Resource: wasmgpu.GPUBufferBinding{Buffer: inputBuf},
-					},
-					{
-						Binding:  1,
-						Resource: wasmgpu.GPUBufferBinding{Buffer: inputSizeBuf},
-					},
-					{
-						Binding:  2,
-						Resource: wasmgpu.GPUBufferBinding{Buffer: resultBuf},
-					},
-					{
-						Binding:  3,
-						Resource: wasmgpu.GPUBufferBinding{Buffer: startBuf},
-					},
-					{
-						Binding:  4,
-						Resource: wasmgpu.GPUBufferBinding{Buffer: prefixBuf},
-					},
-				},
-			})
-
-			cmdEncoder := device.CreateCommandEncoder()
-
-			pass := cmdEncoder.BeginComputePass(opt.V(wasmgpu.GPUComputePassDescriptor{}))
-			pass.SetPipeline(pipeline)
-			pass.SetBindGroup(0, bindGroup, []wasmgpu.GPUBufferDynamicOffset{})
-			pass.DispatchWorkgroups(1, 1, 0)
-			pass.End()
-
-			waitUntilWorkDone(device)
-			device.Queue().Submit([]wasmgpu.GPUCommandBuffer{cmdEncoder.Finish()})
-
-			copyCmd = createCopyCmd(device, resultBuf, tmpBuf, 4)
-			waitUntilWorkDone(device)
-			device.Queue().Submit([]wasmgpu.GPUCommandBuffer{copyCmd})
-
-			mapBuffer(tmpBuf, wasmgpu.GPUMapModeFlagsRead, 4, func(arr js.Value) {
-				nonce := uint32(arr.Index(0).Int())
-				if nonce != 0 {
-					result <- nonce
-				}
-			})
-
-			select {
-			case n = <-result:
-				result <- n
-			default:
-			}
-
-			start += gpuBatchSize
-		}
-	}()
-
-	return result
+	slot.bindGroup = d.device.CreateBindGroup(wasmgpu.GPUBindGroupDescriptor{
+		Layout: d.pipeline.GetBindGroupLayout(0),
+		Entries: []wasmgpu.GPUBindGroupEntry{
+			{
+				Binding:  0,
+				Resource: wasmgpu.GPUBufferBinding{Buffer: d.inputBuf},
+			},
+			{
+				Binding:  1,
+				Resource: wasmgpu.GPUBufferBinding{Buffer: d.inputSizeBuf},
+			},
+			{
+				Binding:  2,
+				Resource: wasmgpu.GPUBufferBinding{Buffer: slot.resultBuf},
+			},
+			{
+				Binding:  3,
+				Resource: wasmgpu.GPUBufferBinding{Buffer: slot.startBuf},
+			},
+			{
+				Binding:  4,
+				Resource: wasmgpu.GPUBufferBinding{Buffer: d.prefixBuf},
+			},
+			{
+				Binding:  5,
+				Resource: wasmgpu.GPUBufferBinding{Buffer: d.paramsBuf},
+			},
+		},
+	})
+
+	return slot
 }
 
-func waitUntilWorkDone(device wasmgpu.GPUDevice) {
-	done := make(chan struct{})
-	device.Queue().ToJS().(js.Value).Call("onSubmittedWorkDone").Call("then",
-		js.FuncOf(func(this js.Value, args []js.Value) any {
-			done <- struct{}{}
-			return nil
-		}))
-	<-done
+// createBuffer creates the buffer of usage holding values.
+func (d *webGPUDevice) createBuffer(usage wasmgpu.GPUBufferUsageFlags, values ...uint32) wasmgpu.GPUBuffer {
+	buf := d.device.CreateBuffer(wasmgpu.GPUBufferDescriptor{
+		Size:             wasmgpu.GPUSize64(len(values) * 4),
+		Usage:            usage,
+		MappedAtCreation: opt.V(true),
+	})
+	uint32Array(buf.GetMappedRange(0, 0)).Call("set", uint32Values(values))
+	buf.Unmap()
+
+	return buf
+}
+
+// Submit writes the start, and queues the dispatch and the copy of its result
+// in a single submit, so that the queue orders them without waiting for the
+// GPU in between.
+func (d *webGPUDevice) Submit(slot int, start uint32) {
+	s := d.slots[slot]
+
+	mapBuffer(s.stagingBuf, wasmgpu.GPUMapModeFlagsWrite, 4, func(arr js.Value) {
+		arr.Call("set", []interface{}{start})
+	})
+
+	cmdEncoder := d.device.CreateCommandEncoder()
+	cmdEncoder.CopyBufferToBuffer(s.stagingBuf, 0, s.startBuf, 0, 4)
+
+	pass := cmdEncoder.BeginComputePass(opt.V(wasmgpu.GPUComputePassDescriptor{}))
+	pass.SetPipeline(d.pipeline)
+	pass.SetBindGroup(0, s.bindGroup, []wasmgpu.GPUBufferDynamicOffset{})
+	// a zero count in any dimension dispatches nothing.
+	pass.DispatchWorkgroups(wasmgpu.GPUSize32(d.cfg.Workgroups), 1, 1)
+	pass.End()
+
+	cmdEncoder.CopyBufferToBuffer(s.resultBuf, 0, s.readBuf, 0, 4)
+	d.device.Queue().Submit([]wasmgpu.GPUCommandBuffer{cmdEncoder.Finish()})
+}
+
+// Wait maps the result of the slot, which resolves once its submit is done.
+func (d *webGPUDevice) Wait(slot int) uint32 {
+	var nonce uint32
+	mapBuffer(d.slots[slot].readBuf, wasmgpu.GPUMapModeFlagsRead, 4, func(arr js.Value) {
+		nonce = uint32(arr.Index(0).Int())
+	})
+
+	return nonce
 }
 
+func (d *webGPUDevice) Destroy() {
+	if !d.uploaded {
+		return
+	}
+
+	d.inputBuf.Destroy()
+	d.inputSizeBuf.Destroy()
+	d.prefixBuf.Destroy()
+	d.paramsBuf.Destroy()
+
+	for _, s := range d.slots {
+		s.stagingBuf.Destroy()
+		s.startBuf.Destroy()
+		s.resultBuf.Destroy()
+		s.readBuf.Destroy()
+	}
+}
+
+// gpuAvailable reports whether the page provides a WebGPU device through getDevice.
+func gpuAvailable() bool {
+	getDevice := js.Global().Get("getDevice")
+	return getDevice.Type() == js.TypeFunction && getDevice.Invoke().Truthy()
+}
+
+// newGPUMiner creates the Miner which runs process.wgsl on WebGPU
+// with the default geometry.
+func newGPUMiner() Miner { return KernelMiner{NewDevice: newWebGPUDevice} }
+
 func mapBuffer(buf wasmgpu.GPUBuffer, mode wasmgpu.GPUMapModeFlags, size wasmgpu.GPUSize64, f func(arr js.Value)) {
 	done := make(chan struct{})
-	buf.MapAsync(mode, 0, size).Call("then",
-		js.FuncOf(func(this js.Value, args []js.Value) any {
-			defer buf.Unmap()
-			defer func() { done <- struct{}{} }()
+	callback := js.FuncOf(func(this js.Value, args []js.Value) any {
+		defer buf.Unmap()
+		defer func() { done <- struct{}{} }()
 
-			arr := uint32Array(buf.GetMappedRange(0, 0))
-			f(arr)
+		arr := uint32Array(buf.GetMappedRange(0, 0))
+		f(arr)
 
-			return nil
-		}))
-	<-done
-}
+		return nil
+	})
+	defer callback.Release()
 
-func createCopyCmd(
-	device wasmgpu.GPUDevice,
-	src wasmgpu.GPUBuffer,
-	dst wasmgpu.GPUBuffer,
-	size wasmgpu.GPUSize64,
-) wasmgpu.GPUCommandBuffer {
-	copyCmdEncoder := device.CreateCommandEncoder()
-	copyCmdEncoder.CopyBufferToBuffer(src, 0, dst, 0, size)
-	return copyCmdEncoder.Finish()
+	buf.MapAsync(mode, 0, size).Call("then", callback)
+	<-done
 }
 
-func bytesToUint32Arr(b []byte) (arr []interface{}) {
-	arr = make([]interface{}, len(b))
-	for i := 0; i < len(arr); i++ {
-		arr[i] = uint32(b[i])
+func uint32Values(values []uint32) (arr []interface{}) {
+	arr = make([]interface{}, len(values))
+	for i, v := range values {
+		arr[i] = v
 	}
 	return
 }
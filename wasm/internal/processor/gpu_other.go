@@ -0,0 +1,8 @@
+//go:build !(js && wasm)
+
+package processor
+
+// WebGPU is only reachable from the browser.
+func gpuAvailable() bool { return false }
+
+func newGPUMiner() Miner { return nil }
@@ -0,0 +1,204 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+)
+
+var (
+	ErrInputTooLong        = errors.New("hash input is too long for the mining kernel")
+	ErrInvalidKernelConfig = errors.New("mining kernel config is not valid")
+)
+
+const (
+	// workgroupSizeX and workgroupSizeY are @workgroup_size of process.wgsl.
+	workgroupSizeX = 8
+	workgroupSizeY = 8
+	// kernelInputCap is the length of the input copy of an invocation.
+	kernelInputCap = 100
+	// maxWorkgroupsPerDimension is maxComputeWorkgroupsPerDimension,
+	// which every WebGPU device supports.
+	maxWorkgroupsPerDimension = 65535
+)
+
+// MaxKernelInput is the longest hash input the kernel can hash with a nonce.
+const MaxKernelInput = kernelInputCap - block.NonceSize
+
+// The defaults of KernelConfig.
+const (
+	DefaultKernelWorkgroups = 64
+	DefaultKernelBatchSize  = 64
+	DefaultKernelDepth      = 2
+)
+
+// KernelConfig is the geometry of the dispatches of the kernel.
+type KernelConfig struct {
+	// Workgroups is the number of the 8x8 workgroups of a dispatch.
+	// DefaultKernelWorkgroups if zero.
+	Workgroups uint32 `json:"workgroups"`
+	// BatchSize is the number of the nonces an invocation hashes.
+	// DefaultKernelBatchSize if zero.
+	BatchSize uint32 `json:"batchSize"`
+	// Depth is the number of the dispatches in flight, so that the GPU has
+	// work queued while the result of the previous one is read back.
+	// DefaultKernelDepth if zero.
+	Depth int `json:"depth"`
+}
+
+// WithDefaults returns the config with the defaults of the zero fields.
+func (c KernelConfig) WithDefaults() KernelConfig {
+	if c.Workgroups == 0 {
+		c.Workgroups = DefaultKernelWorkgroups
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = DefaultKernelBatchSize
+	}
+	if c.Depth <= 0 {
+		c.Depth = DefaultKernelDepth
+	}
+	return c
+}
+
+// Validate checks the config against the default limit of WebGPU, and that a
+// dispatch covers at most half of the nonce space, so that the offsets of the
+// invocations fit in a u32.
+func (c KernelConfig) Validate() error {
+	c = c.WithDefaults()
+	if c.Workgroups > maxWorkgroupsPerDimension {
+		return errors.Wrapf(ErrInvalidKernelConfig, "%d workgroups", c.Workgroups)
+	}
+	if c.DispatchSize() > nonceSpace/2 {
+		return errors.Wrapf(ErrInvalidKernelConfig, "a dispatch covers %d nonces", c.DispatchSize())
+	}
+	return nil
+}
+
+// Width is the number of the invocations in a row of a dispatch.
+func (c KernelConfig) Width() uint32 {
+	return c.WithDefaults().Workgroups * workgroupSizeX
+}
+
+// DispatchSize is the number of the nonces a dispatch covers.
+func (c KernelConfig) DispatchSize() uint64 {
+	c = c.WithDefaults()
+	return uint64(c.Workgroups) * workgroupSizeX * workgroupSizeY * uint64(c.BatchSize)
+}
+
+// KernelDevice runs process.wgsl. Its buffers follow the bindings of the kernel:
+//
+//	0 inputData  the hash input, a byte per u32
+//	1 inputSize  the size of the hash input with the nonce
+//	2 result     the nonce found, 0 until any is found
+//	3 start      the first nonce of the dispatch
+//	4 prefixLen  the difficulty
+//	5 params     the uniform width and batchSize of the dispatch
+//
+// Invocation (x, y) hashes batchSize nonces from
+// start + (x + y*width) * batchSize. Since 0 means not found, nonce 0 is
+// never reported.
+//
+// The device has Depth slots, each of which has its own start and result
+// buffers, so that a dispatch can run while another one is read back.
+type KernelDevice interface {
+	// Upload writes the buffers which are fixed during a search, and
+	// creates the slots of cfg with their results cleared.
+	Upload(inputData []uint32, inputSize, prefixLen uint32, cfg KernelConfig)
+	// Submit queues a dispatch from start on the idle slot.
+	Submit(slot int, start uint32)
+	// Wait waits for the dispatch of the slot, and returns its result.
+	Wait(slot int) uint32
+	// Destroy releases the buffers. The device cannot be used afterwards.
+	Destroy()
+}
+
+// KernelMiner is the Miner which dispatches process.wgsl to a KernelDevice.
+type KernelMiner struct {
+	// NewDevice creates the device of a search.
+	NewDevice func() KernelDevice
+	Config    KernelConfig
+}
+
+func (m KernelMiner) Mine(ctx context.Context, in []byte, difficulty uint8, opts Options) (Result, error) {
+	if len(in) > MaxKernelInput {
+		return Result{}, errors.Wrapf(ErrInputTooLong, "%d bytes", len(in))
+	}
+
+	if err := m.Config.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	device := m.NewDevice()
+	defer device.Destroy()
+
+	nonce, ok := searchKernel(ctx, device, m.Config.WithDefaults(), in, difficulty, opts)
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return Result{}, errors.Wrap(ErrCancelled, err.Error())
+		}
+		return Result{}, ErrNonceNotFound
+	}
+
+	return Result{Hash: block.HashWithNonce(in, nonce), Nonce: nonce}, nil
+}
+
+// searchKernel keeps cfg.Depth dispatches in flight from the start nonce of opts until the kernel reports a
+// nonce, the nonce space is used up or ctx is done. The results are read in
+// the order of submission, so the first solution is the one of the lowest
+// dispatch. Every dispatch is awaited before it returns, so there is no work
+// in flight once it does.
+func searchKernel(ctx context.Context, device KernelDevice, cfg KernelConfig, in []byte, difficulty uint8, opts Options) (uint32, bool) {
+	device.Upload(packKernelInput(in), uint32(len(in)+block.NonceSize), uint32(difficulty), cfg)
+
+	telemetry := opts.Telemetry
+	size := cfg.DispatchSize()
+
+	next := uint64(opts.StartNonce)
+
+	var (
+		pending  []uint64
+		found    uint32
+		finished bool
+	)
+
+	for {
+		for !finished && ctx.Err() == nil && len(pending) < cfg.Depth && next < nonceSpace {
+			device.Submit(int(next/size)%cfg.Depth, uint32(next))
+			pending = append(pending, next)
+			next += size
+		}
+
+		if len(pending) == 0 {
+			return found, found != 0
+		}
+
+		start := pending[0]
+		pending = pending[1:]
+
+		nonce := device.Wait(int(start/size) % cfg.Depth)
+		if finished {
+			continue
+		}
+
+		end := start + size
+		if end > nonceSpace {
+			end = nonceSpace
+		}
+		telemetry.Add(end-start, uint32(start), uint32(end-1))
+
+		if nonce != 0 {
+			found, finished = nonce, true
+		}
+	}
+}
+
+// packKernelInput packs the bytes of the hash input a byte per u32.
+func packKernelInput(in []byte) []uint32 {
+	packed := make([]uint32, len(in))
+	for i, b := range in {
+		packed[i] = uint32(b)
+	}
+	return packed
+}
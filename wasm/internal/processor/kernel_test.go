@@ -0,0 +1,189 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/misc/util"
+	"miner/internal/processor"
+)
+
+// emulatorMiner runs the emulator with a small geometry to keep tests fast.
+var emulatorMiner = processor.KernelMiner{
+	NewDevice: func() processor.KernelDevice { return processor.NewEmulator() },
+	Config:    processor.KernelConfig{Workgroups: 1, BatchSize: 16},
+}
+
+func TestEmulatorMatchesCPUMiner(t *testing.T) {
+	const difficulty = 8
+
+	// the lengths cover the padding within the last block and in an extra one.
+	for _, size := range []int{1, 51, 52, 60, processor.MaxKernelInput} {
+		in := make([]byte, size)
+		for i := range in {
+			in[i] = byte(i*7 + size)
+		}
+
+		want, err := processor.CPUMiner{Workers: 1}.Mine(context.Background(), in, difficulty, processor.Options{})
+		require.NoError(t, err)
+		require.NotZero(t, want.Nonce, "nonce 0 is never reported by the kernel")
+
+		got, err := emulatorMiner.Mine(context.Background(), in, difficulty, processor.Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, want, got, "size %d", size)
+	}
+}
+
+func TestEmulatorGeometry(t *testing.T) {
+	const difficulty = 10
+
+	in := []byte("geometry")
+	want, err := processor.CPUMiner{Workers: 1}.Mine(context.Background(), in, difficulty, processor.Options{})
+	require.NoError(t, err)
+	require.NotZero(t, want.Nonce)
+
+	for _, cfg := range []processor.KernelConfig{
+		{Workgroups: 1, BatchSize: 1, Depth: 1},
+		{Workgroups: 3, BatchSize: 7, Depth: 3},
+		{Workgroups: 2, BatchSize: 100, Depth: 2},
+	} {
+		miner := emulatorMiner
+		miner.Config = cfg
+
+		got, err := miner.Mine(context.Background(), in, difficulty, processor.Options{})
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "%+v", cfg)
+	}
+}
+
+func TestEmulatorSkipsNonceZero(t *testing.T) {
+	const difficulty = 2
+
+	in := []byte{0}
+	for !util.CheckPrefix(block.HashWithNonce(in, 0), difficulty) {
+		in[0]++
+	}
+
+	r, err := emulatorMiner.Mine(context.Background(), in, difficulty, processor.Options{})
+	require.NoError(t, err)
+
+	assert.NotZero(t, r.Nonce)
+	assert.True(t, util.CheckPrefix(r.Hash, difficulty))
+}
+
+func TestKernelMinerInputTooLong(t *testing.T) {
+	_, err := emulatorMiner.Mine(context.Background(), make([]byte, processor.MaxKernelInput+1), 1, processor.Options{})
+	assert.ErrorIs(t, err, processor.ErrInputTooLong)
+}
+
+// fakeDevice reports nonce from the dispatch of index found,
+// and calls onSubmit on every submit if set.
+type fakeDevice struct {
+	found    int
+	nonce    uint32
+	onSubmit func()
+
+	cfg       processor.KernelConfig
+	inputData []uint32
+	inputSize uint32
+	prefixLen uint32
+
+	starts    []uint32
+	slots     []int
+	inFlight  map[int]int
+	maxFlight int
+	waited    []int
+	destroyed bool
+}
+
+func (d *fakeDevice) Upload(inputData []uint32, inputSize, prefixLen uint32, cfg processor.KernelConfig) {
+	d.inputData, d.inputSize, d.prefixLen, d.cfg = inputData, inputSize, prefixLen, cfg
+	d.inFlight = make(map[int]int)
+}
+
+func (d *fakeDevice) Submit(slot int, start uint32) {
+	if _, ok := d.inFlight[slot]; ok {
+		panic("slot is busy")
+	}
+
+	d.inFlight[slot] = len(d.starts)
+	if len(d.inFlight) > d.maxFlight {
+		d.maxFlight = len(d.inFlight)
+	}
+	d.starts = append(d.starts, start)
+	d.slots = append(d.slots, slot)
+
+	if d.onSubmit != nil {
+		d.onSubmit()
+	}
+}
+
+func (d *fakeDevice) Wait(slot int) uint32 {
+	i, ok := d.inFlight[slot]
+	if !ok {
+		panic("slot is idle")
+	}
+	delete(d.inFlight, slot)
+	d.waited = append(d.waited, i)
+
+	if i == d.found {
+		return d.nonce
+	}
+	return 0
+}
+
+func (d *fakeDevice) Destroy() { d.destroyed = true }
+
+func TestKernelMinerProtocol(t *testing.T) {
+	device := &fakeDevice{found: 2, nonce: 12801}
+	miner := processor.KernelMiner{
+		NewDevice: func() processor.KernelDevice { return device },
+		Config:    processor.KernelConfig{Workgroups: 1, BatchSize: 100, Depth: 2},
+	}
+
+	telemetry := processor.NewTelemetry(10)
+
+	r, err := miner.Mine(context.Background(), []byte{0x01, 0xff}, 10, processor.Options{Telemetry: telemetry})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint32(12801), r.Nonce)
+	assert.Equal(t, block.HashWithNonce([]byte{0x01, 0xff}, 12801), r.Hash)
+
+	assert.Equal(t, []uint32{0x01, 0xff}, device.inputData)
+	assert.Equal(t, uint32(2+block.NonceSize), device.inputSize)
+	assert.Equal(t, uint32(10), device.prefixLen)
+	// the dispatch after the solution is already in flight, and is drained.
+	assert.Equal(t, []uint32{0, 6400, 12800, 19200}, device.starts)
+	assert.Equal(t, []int{0, 1, 0, 1}, device.slots)
+	assert.Equal(t, []int{0, 1, 2, 3}, device.waited)
+	assert.Equal(t, 2, device.maxFlight)
+	assert.Equal(t, uint64(3*6400), telemetry.Progress().Hashes)
+	assert.True(t, device.destroyed)
+}
+
+func TestKernelMinerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	device := &fakeDevice{found: -1, onSubmit: cancel}
+	miner := processor.KernelMiner{NewDevice: func() processor.KernelDevice { return device }}
+
+	_, err := miner.Mine(ctx, []byte{0x01}, 255, processor.Options{})
+	assert.ErrorIs(t, err, processor.ErrCancelled)
+	assert.Len(t, device.starts, 1)
+	assert.Len(t, device.waited, 1)
+	assert.True(t, device.destroyed)
+}
+
+func TestKernelConfigValidate(t *testing.T) {
+	assert.NoError(t, processor.KernelConfig{}.Validate())
+	assert.Equal(t, uint64(processor.DefaultKernelWorkgroups*64*processor.DefaultKernelBatchSize), processor.KernelConfig{}.DispatchSize())
+
+	assert.ErrorIs(t, processor.KernelConfig{Workgroups: 70000}.Validate(), processor.ErrInvalidKernelConfig)
+	assert.ErrorIs(t, processor.KernelConfig{Workgroups: 65535, BatchSize: 1 << 20}.Validate(), processor.ErrInvalidKernelConfig)
+}
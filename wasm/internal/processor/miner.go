@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/pow"
+)
+
+var (
+	ErrNonceNotFound      = errors.New("nonce space is used up without a solution")
+	ErrCancelled          = errors.New("mining is cancelled")
+	ErrBackendUnavailable = errors.New("mining backend is not available")
+	ErrUnknownBackend     = errors.New("unknown mining backend")
+)
+
+// Result is the nonce found by a miner and the proof-of-work hash of the
+// header with it.
+type Result struct {
+	Hash  []byte
+	Nonce uint32
+}
+
+// Options are the optional inputs of a search.
+type Options struct {
+	// StartNonce is the nonce the search starts from, e.g. to resume a search
+	// whose lower nonces are searched.
+	StartNonce uint32
+	// Telemetry is reported the progress of the search. Ignored if nil.
+	Telemetry *Telemetry
+}
+
+// Miner searches the nonce of a block header.
+type Miner interface {
+	// Mine searches the nonce with which the hash of the header, whose hash
+	// input is in, meets difficulty. It returns ErrNonceNotFound when every
+	// nonce is searched without a solution, and ErrCancelled when ctx is done
+	// before a solution is found.
+	Mine(ctx context.Context, in []byte, difficulty uint8, opts Options) (Result, error)
+}
+
+// Backend is the kind of hardware a miner runs on.
+type Backend string
+
+const (
+	// BackendAuto selects the GPU if available, otherwise the CPU.
+	BackendAuto Backend = "auto"
+	BackendCPU  Backend = "cpu"
+	BackendGPU  Backend = "gpu"
+)
+
+// NewMiner creates the miner of backend for alg. It returns the backend
+// actually selected, which differs from the given one only for BackendAuto.
+// The GPU kernel only implements pow.SHA256.
+func NewMiner(backend Backend, alg pow.Algorithm) (Miner, Backend, error) {
+	cpu := CPUMiner{Algorithm: alg}
+
+	switch backend {
+	case BackendAuto:
+		if gpuAvailable() && alg == pow.SHA256 {
+			return newGPUMiner(), BackendGPU, nil
+		}
+		return cpu, BackendCPU, nil
+	case BackendCPU:
+		return cpu, BackendCPU, nil
+	case BackendGPU:
+		if !gpuAvailable() {
+			return nil, "", errors.Wrap(ErrBackendUnavailable, "WebGPU is not supported")
+		}
+		if alg != pow.SHA256 {
+			return nil, "", errors.Wrapf(ErrBackendUnavailable, "GPU does not support %s", alg.Name())
+		}
+		return newGPUMiner(), BackendGPU, nil
+	}
+
+	return nil, "", errors.Wrapf(ErrUnknownBackend, "%q", backend)
+}
@@ -0,0 +1,78 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"miner/internal/block"
+	"miner/internal/misc/util"
+	"miner/internal/pow"
+	"miner/internal/processor"
+	"miner/internal/versionbits"
+)
+
+func TestNewMiner(t *testing.T) {
+	// WebGPU is not available in tests.
+	miner, backend, err := processor.NewMiner(processor.BackendAuto, pow.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, processor.BackendCPU, backend)
+	assert.IsType(t, processor.CPUMiner{}, miner)
+
+	_, backend, err = processor.NewMiner(processor.BackendCPU, pow.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, processor.BackendCPU, backend)
+
+	_, _, err = processor.NewMiner(processor.BackendGPU, pow.SHA256)
+	assert.ErrorIs(t, err, processor.ErrBackendUnavailable)
+
+	_, _, err = processor.NewMiner("tpu", pow.SHA256)
+	assert.ErrorIs(t, err, processor.ErrUnknownBackend)
+
+	// the GPU kernel only implements SHA-256.
+	miner, backend, err = processor.NewMiner(processor.BackendAuto, pow.DefaultScrypt)
+	require.NoError(t, err)
+	assert.Equal(t, processor.BackendCPU, backend)
+	assert.Equal(t, processor.CPUMiner{Algorithm: pow.DefaultScrypt}, miner)
+}
+
+func TestCPUMinerAlgorithm(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	header := &block.Header{
+		Version:    versionbits.TopBits,
+		PrevHash:   []byte{0x00},
+		DataHash:   []byte{0x33},
+		Timestamp:  time.Unix(0, 1700000000000000000),
+		Difficulty: 6,
+	}
+
+	for _, alg := range []pow.Algorithm{pow.DoubleSHA256, pow.DefaultScrypt} {
+		t.Run(alg.Name(), func(t *testing.T) {
+			miner := processor.CPUMiner{Algorithm: alg}
+			r, err := miner.Mine(context.Background(), header.MakeHashInput(), header.Difficulty, processor.Options{})
+			require.NoError(t, err)
+
+			header.Nonce = r.Nonce
+			assert.Equal(t, r.Hash, header.PowHash(alg))
+			assert.True(t, util.CheckPrefix(r.Hash, header.Difficulty))
+		})
+	}
+}
+
+func TestCPUMinerCancel(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := processor.CPUMiner{}.Mine(ctx, []byte{0x11, 0x53}, 255, processor.Options{})
+
+	assert.ErrorIs(t, err, processor.ErrCancelled)
+	assert.Less(t, time.Since(start), time.Second)
+}
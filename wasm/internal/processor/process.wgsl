@@ -3,14 +3,21 @@
 @group(0) @binding(2) var<storage, read_write> result : u32;
 @group(0) @binding(3) var<storage, read> start : u32;
 @group(0) @binding(4) var<storage, read> prefixLen : u32;
-
-const CORE_BATCH_SIZE = 100;
+@group(0) @binding(5) var<uniform> params : Params;
+
+// Params is the geometry of the dispatch.
+struct Params {
+  // width is the number of the invocations in a row, 8 * workgroups.
+  width : u32,
+  // batchSize is the number of the nonces an invocation hashes.
+  batchSize : u32,
+};
 
 @compute @workgroup_size(8, 8)
 fn main(@builtin(global_invocation_id) global_id : vec3<u32>) {
   if (result != 0) { return; }
 
-  var nonce : u32 = start + (global_id.x + (global_id.y * 8)) * CORE_BATCH_SIZE;
+  var nonce : u32 = start + (global_id.x + (global_id.y * params.width)) * params.batchSize;
   
   var inputCopy : array<u32, 100>;
   for (var i : u32 = 0; i < u32(arrayLength(&inputData)); i++) {
@@ -19,7 +26,7 @@ fn main(@builtin(global_invocation_id) global_id : vec3<u32>) {
 
   var last : u32 = arrayLength(&inputData);
 
-  for (var i = 0; i < CORE_BATCH_SIZE; i++) {
+  for (var i : u32 = 0; i < params.batchSize; i++) {
     if (result != 0) { break; }
 
     inputCopy[last] = nonce & 0xFF;
@@ -0,0 +1,174 @@
+package processor
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of a running search.
+type Progress struct {
+	// Hashes is the number of the hashes tried so far.
+	Hashes uint64 `json:"hashes"`
+	// HashRate is the number of the hashes tried per second over the last
+	// RateWindow, so that it follows the throttle of the miner.
+	HashRate float64 `json:"hashRate"`
+	// NonceStart and NonceEnd are the last searched nonce range, inclusive.
+	NonceStart uint32 `json:"nonceStart"`
+	NonceEnd   uint32 `json:"nonceEnd"`
+	// Elapsed is the time since the search started.
+	Elapsed time.Duration `json:"elapsed"`
+	// ExpectedTime is the expected time to find a block
+	// at the current hash rate and difficulty.
+	ExpectedTime time.Duration `json:"expectedTime"`
+}
+
+// RateWindow is the period the hash rate is measured over.
+const RateWindow = 5 * time.Second
+
+// rateSample is the number of the hashes tried by a time.
+type rateSample struct {
+	at     time.Time
+	hashes uint64
+}
+
+// Telemetry measures the progress of the miners. Its methods are safe for
+// concurrent use, and a nil Telemetry ignores every report.
+//
+// It also tracks the nonces of the header searched contiguously from the
+// start nonce, so that an interrupted search can resume without repeating
+// them. The workers finish their ranges out of order, so the ranges above
+// the searched nonces are kept until the gap below them is filled.
+type Telemetry struct {
+	mu         sync.Mutex
+	difficulty uint8
+	startedAt  time.Time
+	hashes     uint64
+	nonceStart uint32
+	nonceEnd   uint32
+
+	searched uint64
+	ranges   map[uint64]uint64
+
+	// samples are taken every tenth of RateWindow, oldest first, and the
+	// first one is the last before the window.
+	samples []rateSample
+}
+
+func NewTelemetry(difficulty uint8) *Telemetry {
+	now := time.Now()
+	return &Telemetry{
+		difficulty: difficulty,
+		startedAt:  now,
+		ranges:     make(map[uint64]uint64),
+		samples:    []rateSample{{at: now}},
+	}
+}
+
+// Add records that hashes of the nonce range [start, end] are tried, which
+// are the first hashes nonces from start.
+func (t *Telemetry) Add(hashes uint64, start, end uint32) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.hashes += hashes
+	t.nonceStart, t.nonceEnd = start, end
+	t.sample(time.Now())
+
+	if hashes == 0 {
+		return
+	}
+	if rangeEnd := uint64(start) + hashes; rangeEnd > t.ranges[uint64(start)] {
+		t.ranges[uint64(start)] = rangeEnd
+	}
+	t.mergeRanges()
+}
+
+// sample records the hashes tried by now, and drops the samples out of the window.
+func (t *Telemetry) sample(now time.Time) {
+	if now.Sub(t.samples[len(t.samples)-1].at) >= RateWindow/10 {
+		t.samples = append(t.samples, rateSample{at: now, hashes: t.hashes})
+	}
+
+	windowStart := now.Add(-RateWindow)
+	for len(t.samples) > 1 && !t.samples[1].at.After(windowStart) {
+		t.samples = t.samples[1:]
+	}
+}
+
+// mergeRanges advances the searched nonces over the ranges reaching them.
+func (t *Telemetry) mergeRanges() {
+	for merged := true; merged; {
+		merged = false
+		for start, end := range t.ranges {
+			if start > t.searched {
+				continue
+			}
+
+			if end > t.searched {
+				t.searched = end
+			}
+			delete(t.ranges, start)
+			merged = true
+		}
+	}
+}
+
+// StartAt starts tracking the searched nonces of a new header from start,
+// whose lower nonces are already searched.
+func (t *Telemetry) StartAt(start uint32) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.searched = uint64(start)
+	t.ranges = make(map[uint64]uint64)
+}
+
+// Searched returns the nonce from which the search of the header can resume,
+// since every lower nonce from the start nonce is searched.
+func (t *Telemetry) Searched() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.searched
+}
+
+// Progress returns the current progress.
+func (t *Telemetry) Progress() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.sample(now)
+
+	elapsed := now.Sub(t.startedAt)
+	p := Progress{
+		Hashes:     t.hashes,
+		NonceStart: t.nonceStart,
+		NonceEnd:   t.nonceEnd,
+		Elapsed:    elapsed,
+	}
+
+	if oldest := t.samples[0]; now.After(oldest.at) {
+		p.HashRate = float64(t.hashes-oldest.hashes) / now.Sub(oldest.at).Seconds()
+	}
+	if p.HashRate > 0 {
+		p.ExpectedTime = time.Duration(ExpectedHashes(t.difficulty) / p.HashRate * float64(time.Second))
+	}
+
+	return p
+}
+
+// ExpectedHashes returns the expected number of the hashes
+// to find a hash with difficulty leading zero bits.
+func ExpectedHashes(difficulty uint8) float64 {
+	return math.Ldexp(1, int(difficulty))
+}
@@ -0,0 +1,81 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"miner/internal/processor"
+)
+
+func TestTelemetry(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	telemetry := processor.NewTelemetry(12)
+
+	_, err := processor.CPUMiner{}.Mine(context.Background(), []byte{0x11, 0x53, 0x42, 0xFF, 0xEA}, 12, processor.Options{Telemetry: telemetry})
+	require.NoError(t, err)
+
+	p := telemetry.Progress()
+	assert.NotZero(t, p.Hashes)
+	assert.Positive(t, p.HashRate)
+	assert.Positive(t, p.Elapsed)
+	assert.Positive(t, p.ExpectedTime)
+	assert.LessOrEqual(t, p.NonceStart, p.NonceEnd)
+
+	// nil telemetry ignores reports.
+	var nilTelemetry *processor.Telemetry
+	nilTelemetry.Add(1, 0, 0)
+}
+
+func TestTelemetrySearched(t *testing.T) {
+	telemetry := processor.NewTelemetry(12)
+	telemetry.StartAt(100)
+
+	// ranges above a gap are kept until it is filled.
+	telemetry.Add(10, 110, 119)
+	telemetry.Add(5, 130, 134)
+	assert.Equal(t, uint64(100), telemetry.Searched())
+
+	telemetry.Add(10, 100, 109)
+	assert.Equal(t, uint64(120), telemetry.Searched())
+
+	// a cancelled range covers only its first hashes.
+	telemetry.Add(4, 120, 123)
+	assert.Equal(t, uint64(124), telemetry.Searched())
+
+	telemetry.StartAt(0)
+	assert.Equal(t, uint64(0), telemetry.Searched())
+	assert.Equal(t, uint64(29), telemetry.Progress().Hashes)
+}
+
+func TestStartNonce(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	in := []byte{0x11, 0x53, 0x42}
+	miner := processor.CPUMiner{Workers: 1}
+
+	first, err := miner.Mine(context.Background(), in, 8, processor.Options{})
+	require.NoError(t, err)
+
+	telemetry := processor.NewTelemetry(8)
+	telemetry.StartAt(first.Nonce + 1)
+	opts := processor.Options{StartNonce: first.Nonce + 1, Telemetry: telemetry}
+
+	next, err := miner.Mine(context.Background(), in, 8, opts)
+	require.NoError(t, err)
+	assert.Greater(t, next.Nonce, first.Nonce)
+	assert.Greater(t, telemetry.Searched(), uint64(next.Nonce))
+
+	kernelNext, err := emulatorMiner.Mine(context.Background(), in, 8, processor.Options{StartNonce: first.Nonce + 1})
+	require.NoError(t, err)
+	assert.Equal(t, next, kernelNext)
+}
+
+func TestExpectedHashes(t *testing.T) {
+	assert.Equal(t, float64(1), processor.ExpectedHashes(0))
+	assert.Equal(t, float64(1<<22), processor.ExpectedHashes(22))
+}
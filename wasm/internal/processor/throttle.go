@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidThrottle = errors.New("CPU throttle is not valid")
+
+// ThrottlePeriod is the period the duty cycle of a worker applies to.
+const ThrottlePeriod = 100 * time.Millisecond
+
+// ThrottleConfig limits the CPU the CPU miner uses.
+type ThrottleConfig struct {
+	// Workers is the number of the workers allowed to hash, and the others
+	// are parked. Every worker of the miner if zero, so it cannot add workers
+	// to a running miner.
+	Workers int `json:"workers"`
+	// DutyCycle is the fraction of ThrottlePeriod a worker hashes in, and it
+	// sleeps in the rest. 1 if zero.
+	DutyCycle float64 `json:"dutyCycle"`
+}
+
+// Validate checks the worker count is not negative and the duty cycle is in [0, 1].
+func (c ThrottleConfig) Validate() error {
+	if c.Workers < 0 {
+		return errors.Wrapf(ErrInvalidThrottle, "%d workers", c.Workers)
+	}
+	if c.DutyCycle < 0 || c.DutyCycle > 1 {
+		return errors.Wrapf(ErrInvalidThrottle, "duty cycle %v", c.DutyCycle)
+	}
+	return nil
+}
+
+// Throttle is the ThrottleConfig of the running miners, which can be changed
+// while they run. It is safe for concurrent use, and a nil or zero Throttle
+// does not limit anything.
+type Throttle struct {
+	mu  sync.Mutex
+	cfg ThrottleConfig
+	// changed is closed when the config changes, so that the resting
+	// workers apply it at once.
+	changed chan struct{}
+}
+
+func NewThrottle(cfg ThrottleConfig) (*Throttle, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Throttle{cfg: cfg, changed: make(chan struct{})}, nil
+}
+
+// Set changes the config of the running miners.
+func (t *Throttle) Set(cfg ThrottleConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cfg = cfg
+	if t.changed != nil {
+		close(t.changed)
+	}
+	t.changed = make(chan struct{})
+
+	return nil
+}
+
+func (t *Throttle) Config() ThrottleConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.cfg
+}
+
+func (t *Throttle) state() (ThrottleConfig, <-chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.cfg, t.changed
+}
+
+// park blocks the worker while the config does not allow it to hash, and
+// resets busySince if it has been parked. It reports false if ctx is done or
+// done is closed meanwhile.
+func (t *Throttle) park(ctx context.Context, worker int, busySince *time.Time, done <-chan struct{}) bool {
+	if t == nil {
+		return ctx.Err() == nil
+	}
+
+	for {
+		cfg, changed := t.state()
+		if cfg.Workers == 0 || worker < cfg.Workers {
+			return ctx.Err() == nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-done:
+			return false
+		case <-changed:
+			*busySince = time.Now()
+		}
+	}
+}
+
+// pause sleeps for the part of the duty cycle matching the time the worker
+// has hashed since busySince, once it has hashed for its part of
+// ThrottlePeriod. busySince is reset when it resumes. It reports false if ctx
+// is done meanwhile.
+func (t *Throttle) pause(ctx context.Context, busySince *time.Time) bool {
+	if t == nil {
+		return ctx.Err() == nil
+	}
+
+	for {
+		cfg, changed := t.state()
+
+		busy := time.Since(*busySince)
+		if cfg.DutyCycle == 0 || cfg.DutyCycle == 1 ||
+			busy < time.Duration(cfg.DutyCycle*float64(ThrottlePeriod)) {
+			return ctx.Err() == nil
+		}
+
+		timer := time.NewTimer(time.Duration(float64(busy) * (1 - cfg.DutyCycle) / cfg.DutyCycle))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+			*busySince = time.Now()
+			return true
+		case <-changed:
+			// the sleep is recalculated with the new duty cycle.
+			timer.Stop()
+			*busySince = time.Now()
+		}
+	}
+}
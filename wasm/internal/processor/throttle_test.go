@@ -0,0 +1,99 @@
+package processor_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"miner/internal/processor"
+)
+
+// js/wasm runtime keeps a goroutine to handle the events of the timers.
+var ignoreJSEvents = goleak.IgnoreAnyFunction("runtime.handleEvent")
+
+// hashesWithin returns the number of the hashes miner tries within d.
+func hashesWithin(t *testing.T, miner processor.CPUMiner, d time.Duration) uint64 {
+	telemetry := processor.NewTelemetry(255)
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	_, err := miner.Mine(ctx, []byte{0x11, 0x53}, 255, processor.Options{Telemetry: telemetry})
+	require.ErrorIs(t, err, processor.ErrCancelled)
+
+	return telemetry.Progress().Hashes
+}
+
+func TestThrottleDutyCycle(t *testing.T) {
+	defer goleak.VerifyNone(t, ignoreJSEvents)
+
+	full := hashesWithin(t, processor.CPUMiner{Workers: 1}, 500*time.Millisecond)
+
+	throttle, err := processor.NewThrottle(processor.ThrottleConfig{DutyCycle: 0.25})
+	require.NoError(t, err)
+	throttled := hashesWithin(t, processor.CPUMiner{Workers: 1, Throttle: throttle}, 500*time.Millisecond)
+
+	assert.Positive(t, throttled)
+	assert.Less(t, throttled, full/2)
+}
+
+func TestThrottleSetWhileMining(t *testing.T) {
+	defer goleak.VerifyNone(t, ignoreJSEvents)
+
+	// every worker but one is parked, and the only one hashes 1% of the time.
+	throttle, err := processor.NewThrottle(processor.ThrottleConfig{Workers: 1, DutyCycle: 0.01})
+	require.NoError(t, err)
+
+	telemetry := processor.NewTelemetry(255)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		_, err := processor.CPUMiner{Workers: 2, Throttle: throttle}.Mine(ctx, []byte{0x11, 0x53}, 255, processor.Options{Telemetry: telemetry})
+		done <- err
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	throttled := telemetry.Progress().Hashes
+
+	require.NoError(t, throttle.Set(processor.ThrottleConfig{}))
+	assert.Equal(t, processor.ThrottleConfig{}, throttle.Config())
+
+	time.Sleep(300 * time.Millisecond)
+	full := telemetry.Progress().Hashes - throttled
+
+	cancel()
+	assert.ErrorIs(t, <-done, processor.ErrCancelled)
+	assert.Greater(t, full, 4*throttled)
+}
+
+func TestThrottleParkedWorkersStop(t *testing.T) {
+	defer goleak.VerifyNone(t, ignoreJSEvents)
+
+	throttle, err := processor.NewThrottle(processor.ThrottleConfig{Workers: 1})
+	require.NoError(t, err)
+
+	// the search ends once the nonces are used up, although workers are parked.
+	opts := processor.Options{StartNonce: math.MaxUint32 - 10000}
+	_, err = processor.CPUMiner{Workers: 4, BatchSize: 100, Throttle: throttle}.Mine(context.Background(), []byte{0x11, 0x53}, 255, opts)
+	assert.ErrorIs(t, err, processor.ErrNonceNotFound)
+}
+
+func TestThrottleConfigValidate(t *testing.T) {
+	assert.NoError(t, processor.ThrottleConfig{}.Validate())
+	assert.NoError(t, processor.ThrottleConfig{Workers: 2, DutyCycle: 0.5}.Validate())
+	assert.ErrorIs(t, processor.ThrottleConfig{Workers: -1}.Validate(), processor.ErrInvalidThrottle)
+	assert.ErrorIs(t, processor.ThrottleConfig{DutyCycle: 1.5}.Validate(), processor.ErrInvalidThrottle)
+	assert.ErrorIs(t, processor.ThrottleConfig{DutyCycle: -0.5}.Validate(), processor.ErrInvalidThrottle)
+
+	throttle, err := processor.NewThrottle(processor.ThrottleConfig{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, throttle.Set(processor.ThrottleConfig{Workers: -1}), processor.ErrInvalidThrottle)
+
+	_, err = processor.NewThrottle(processor.ThrottleConfig{DutyCycle: 2})
+	assert.ErrorIs(t, err, processor.ErrInvalidThrottle)
+}
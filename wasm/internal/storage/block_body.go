@@ -4,9 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"syscall/js"
 
-	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/pkg/errors"
 
 	"miner/internal/block"
@@ -19,19 +17,18 @@ import (
 // FindBlockHeader finds block header of given blockHash
 func FindBlockBody(ctx context.Context, blockHash hash.Hash) (*block.Body, error) {
 	var dst block.Body
-	err := withTx(idb.TransactionReadOnly, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreBlockBody)
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreBlockBody)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
 
-		req, _ := objStore.Get(js.ValueOf(util.BytesToStr(blockHash.ToHex())))
-		val, err := req.Await(ctx)
+		val, _, err := objStore.get(ctx, util.BytesToStr(blockHash.ToHex()))
 		if err != nil {
-			return errors.Wrap(err, "request failed")
+			return err
 		}
 
-		if err := json.Unmarshal(util.FromJSObject(val), &dst); err != nil {
+		if err := json.Unmarshal(val, &dst); err != nil {
 			return errors.Wrap(err, "failed to unmarshal block")
 		}
 
@@ -46,8 +43,8 @@ func FindBlockBody(ctx context.Context, blockHash hash.Hash) (*block.Body, error
 }
 
 func InsertBlockBody(ctx context.Context, hash hash.Hash, body *block.Body) error {
-	return withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreBlockBody)
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreBlockBody)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
@@ -57,12 +54,7 @@ func InsertBlockBody(ctx context.Context, hash hash.Hash, body *block.Body) erro
 			return errors.Wrap(err, "failed to marshal block")
 		}
 
-		objStore.AddKey(
-			js.ValueOf(util.BytesToStr(hash.ToHex())),
-			util.ToJSObject(b),
-		)
-
-		return nil
+		return objStore.add(ctx, util.BytesToStr(hash.ToHex()), b)
 	}, ObjStoreBlockBody)
 }
 
@@ -73,20 +65,19 @@ func FindUTxOutputs(ctx context.Context, pubKey []byte) (_ []*tx.UTxOutput, got
 
 	var cur hash.Hash = blockchain.HeadHash
 
-	err = withTx(idb.TransactionReadOnly, func(tranx *idb.Transaction) error {
-		txStorage, _ := tranx.ObjectStore(ObjStoreTransaction)
-		blockHeaderStorage, _ := tranx.ObjectStore(ObjStoreBlockHeader)
-		blockBodyStorage, _ := tranx.ObjectStore(ObjStoreBlockBody)
+	err = withTx(txReadOnly, func(tranx dbTransaction) error {
+		txStorage, _ := tranx.objectStore(ObjStoreTransaction)
+		blockHeaderStorage, _ := tranx.objectStore(ObjStoreBlockHeader)
+		blockBodyStorage, _ := tranx.objectStore(ObjStoreBlockBody)
 
 		for {
-			req, _ := blockHeaderStorage.Get(js.ValueOf(util.BytesToStr(cur.ToHex())))
-			val, err := req.Await(ctx)
+			val, _, err := blockHeaderStorage.get(ctx, util.BytesToStr(cur.ToHex()))
 			if err != nil {
-				return errors.Wrap(err, "request failed")
+				return err
 			}
 
 			var header block.Header
-			if err := json.Unmarshal(util.FromJSObject(val), &header); err != nil {
+			if err := json.Unmarshal(val, &header); err != nil {
 				return errors.Wrap(err, "failed to unmarshal block header")
 			}
 
@@ -94,30 +85,28 @@ func FindUTxOutputs(ctx context.Context, pubKey []byte) (_ []*tx.UTxOutput, got
 				return nil
 			}
 
-			req, _ = blockBodyStorage.Get(js.ValueOf(util.BytesToStr(header.CurHash.ToHex())))
-			val, err = req.Await(ctx)
+			val, _, err = blockBodyStorage.get(ctx, util.BytesToStr(header.CurHash.ToHex()))
 			if err != nil {
-				return errors.Wrap(err, "request failed")
+				return err
 			}
 
 			var body block.Body
-			if err := json.Unmarshal(util.FromJSObject(val), &body); err != nil {
+			if err := json.Unmarshal(val, &body); err != nil {
 				return errors.Wrap(err, "failed to unmarshal block body")
 			}
 
 			var transaction tx.Transaction
 			for _, txHash := range append(body.TxHashes, body.CoinbaseTxHash) {
-				req, _ = txStorage.Get(js.ValueOf(util.BytesToStr(txHash.ToHex())))
-				val, err = req.Await(ctx)
+				val, found, err := txStorage.get(ctx, util.BytesToStr(txHash.ToHex()))
 				if err != nil {
-					return errors.Wrap(err, "request failed")
+					return err
 				}
 
-				if val.IsUndefined() {
+				if !found {
 					continue
 				}
 
-				if err := json.Unmarshal(util.FromJSObject(val), &transaction); err != nil {
+				if err := json.Unmarshal(val, &transaction); err != nil {
 					return errors.Wrap(err, "failed to unmarshal transaction")
 				}
 
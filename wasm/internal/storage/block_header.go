@@ -3,18 +3,17 @@ package storage
 import (
 	"context"
 	"encoding/json"
-	"syscall/js"
 
-	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/pkg/errors"
 
 	"miner/internal/block"
+	"miner/internal/hash"
 	"miner/internal/misc/util"
 )
 
 func InsertBlockHeader(ctx context.Context, header *block.Header) error {
-	return withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreBlockHeader)
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreBlockHeader)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
@@ -24,13 +23,76 @@ func InsertBlockHeader(ctx context.Context, header *block.Header) error {
 			return errors.Wrap(err, "failed to marshal block")
 		}
 
-		objStore.AddKey(
-			js.ValueOf(util.BytesToStr(header.CurHash.ToHex())),
-			util.ToJSObject(b),
-		)
+		return objStore.add(ctx, util.BytesToStr(header.CurHash.ToHex()), b)
+	}, ObjStoreBlockHeader)
+}
+
+// HasBlockHeader reports whether the header of given blockHash is stored.
+func HasBlockHeader(ctx context.Context, blockHash hash.Hash) (bool, error) {
+	var found bool
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreBlockHeader)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		_, found, err = objStore.get(ctx, util.BytesToStr(blockHash.ToHex()))
+		return err
+	}, ObjStoreBlockHeader)
+
+	return found, err
+}
+
+// FindBlockHeader finds the header of given blockHash. It returns nil if there is none.
+func FindBlockHeader(ctx context.Context, blockHash hash.Hash) (*block.Header, error) {
+	var header *block.Header
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreBlockHeader)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		val, found, err := objStore.get(ctx, util.BytesToStr(blockHash.ToHex()))
+		if err != nil || !found {
+			return err
+		}
+
+		header = new(block.Header)
+		if err := json.Unmarshal(val, header); err != nil {
+			return errors.Wrap(err, "failed to unmarshal block header")
+		}
 
 		return nil
 	}, ObjStoreBlockHeader)
+
+	return header, err
+}
+
+// FindBlockHeaders finds every stored block header in no particular order.
+func FindBlockHeaders(ctx context.Context) ([]*block.Header, error) {
+	headers := make([]*block.Header, 0)
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreBlockHeader)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		return objStore.iterate(ctx, func(_ string, val []byte) (bool, error) {
+			var dst block.Header
+			if err := json.Unmarshal(val, &dst); err != nil {
+				return false, errors.Wrap(err, "failed to unmarshal block header")
+			}
+
+			headers = append(headers, &dst)
+			return true, nil
+		})
+	}, ObjStoreBlockHeader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return headers, nil
 }
 
 func FindBlockchainHead() ([]byte, error) {
@@ -38,15 +100,15 @@ func FindBlockchainHead() ([]byte, error) {
 	var cur, ref string
 	refMap := make(map[string]string)
 
-	err := withTx(idb.TransactionReadOnly, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreBlockHeader)
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreBlockHeader)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
 
 		var header block.Header
-		err = iterate(context.Background(), objStore, func(_, val js.Value) (bool, error) {
-			if err := json.Unmarshal(util.FromJSObject(val), &header); err != nil {
+		err = objStore.iterate(context.Background(), func(_ string, val []byte) (bool, error) {
+			if err := json.Unmarshal(val, &header); err != nil {
 				return false, errors.Wrap(err, "failed to unmarshal block")
 			}
 
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/checkpoint"
+)
+
+// miningCheckpointKey is the key of the checkpoint of the block being mined.
+// Only the latest checkpoint is kept.
+const miningCheckpointKey = "mining"
+
+// PutCheckpoint replaces the mining checkpoint.
+func PutCheckpoint(ctx context.Context, cp *checkpoint.Checkpoint) error {
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreCheckpoint)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		b, err := json.Marshal(cp)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal checkpoint")
+		}
+
+		return objStore.put(ctx, miningCheckpointKey, b)
+	}, ObjStoreCheckpoint)
+}
+
+// FindCheckpoint finds the mining checkpoint. It returns nil if there is none.
+func FindCheckpoint(ctx context.Context) (*checkpoint.Checkpoint, error) {
+	var cp *checkpoint.Checkpoint
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreCheckpoint)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		val, found, err := objStore.get(ctx, miningCheckpointKey)
+		if err != nil || !found {
+			return err
+		}
+
+		cp = new(checkpoint.Checkpoint)
+		if err := json.Unmarshal(val, cp); err != nil {
+			return errors.Wrap(err, "failed to unmarshal checkpoint")
+		}
+
+		return nil
+	}, ObjStoreCheckpoint)
+
+	return cp, err
+}
+
+// DeleteCheckpoint deletes the mining checkpoint.
+func DeleteCheckpoint(ctx context.Context) error {
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreCheckpoint)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		return objStore.delete(ctx, miningCheckpointKey)
+	}, ObjStoreCheckpoint)
+}
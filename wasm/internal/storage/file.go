@@ -0,0 +1,350 @@
+//go:build !(js && wasm)
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Dir is the directory of the database outside of the browser. Each object
+// store is a subdirectory, which has a file per key. It should be set before
+// InitDB.
+var Dir = "blockchain"
+
+var ErrInvalidKey = errors.New("key is not valid")
+
+// valueExt is the extension of the value files.
+const valueExt = ".json"
+
+// journalName is the file in Dir which has the writes of the transaction being
+// committed, until all of them are written.
+const journalName = "journal.json"
+
+// fileMu serializes the read-write transactions, while the read-only ones
+// run concurrently.
+var fileMu sync.RWMutex
+
+func openDB(ctx context.Context) error {
+	for _, name := range objStores {
+		if err := os.MkdirAll(filepath.Join(Dir, name), 0o755); err != nil {
+			return errors.Wrap(err, "failed to create object store")
+		}
+	}
+
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	return recoverJournal()
+}
+
+// withTx runs f in a transaction. The writes of a read-write transaction are
+// kept until f returns, and only committed if it succeeds.
+func withTx(mode txMode, f func(tranx dbTransaction) error, objStoreName string, objStoreNames ...string) error {
+	if mode == txReadWrite {
+		fileMu.Lock()
+		defer fileMu.Unlock()
+	} else {
+		fileMu.RLock()
+		defer fileMu.RUnlock()
+	}
+
+	tranx := &fileTransaction{
+		mode:   mode,
+		stores: make(map[string]*fileObjectStore),
+	}
+	for _, name := range append([]string{objStoreName}, objStoreNames...) {
+		tranx.stores[name] = &fileObjectStore{
+			dir:    filepath.Join(Dir, name),
+			mode:   mode,
+			writes: make(map[string][]byte),
+		}
+	}
+
+	if err := f(tranx); err != nil {
+		return err
+	}
+
+	return tranx.commit()
+}
+
+type fileTransaction struct {
+	mode   txMode
+	stores map[string]*fileObjectStore
+}
+
+func (t *fileTransaction) objectStore(name string) (objectStore, error) {
+	s, ok := t.stores[name]
+	if !ok {
+		return nil, errors.Errorf("object store %q is not in the transaction", name)
+	}
+	return s, nil
+}
+
+// commit writes the values of the transaction atomically. They are synced to
+// the journal first, which is applied again by openDB or the next commit if
+// the values are not all written, e.g. because the process stopped.
+func (t *fileTransaction) commit() error {
+	var entries []journalEntry
+	for name, s := range t.stores {
+		for key, val := range s.writes {
+			entries = append(entries, journalEntry{Store: name, Key: key, Value: val})
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// the journal of a failed commit should not be overwritten.
+	if err := recoverJournal(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal journal")
+	}
+
+	if err := writeFile(filepath.Join(Dir, journalName), data); err != nil {
+		return errors.Wrap(err, "failed to write journal")
+	}
+	if err := syncDir(Dir); err != nil {
+		return errors.Wrap(err, "failed to write journal")
+	}
+
+	if err := applyJournal(entries); err != nil {
+		return err
+	}
+	return removeJournal()
+}
+
+// journalEntry is a write of a transaction.
+type journalEntry struct {
+	Store string `json:"store"`
+	Key   string `json:"key"`
+	// Value is nil if the key is deleted.
+	Value []byte `json:"value"`
+}
+
+// recoverJournal applies the journal left by a commit which did not finish.
+func recoverJournal() error {
+	data, err := os.ReadFile(filepath.Join(Dir, journalName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read journal")
+	}
+
+	var entries []journalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return errors.Wrap(err, "failed to unmarshal journal")
+	}
+
+	if err := applyJournal(entries); err != nil {
+		return err
+	}
+	return removeJournal()
+}
+
+// applyJournal writes the values of the entries, and syncs them. Writing the
+// same entries again has no other effect, so it can be retried.
+func applyJournal(entries []journalEntry) error {
+	dirs := make(map[string]struct{})
+	for _, entry := range entries {
+		if err := checkKey(entry.Key); err != nil {
+			return err
+		}
+
+		dir := filepath.Join(Dir, entry.Store)
+		path := filepath.Join(dir, entry.Key+valueExt)
+		dirs[dir] = struct{}{}
+
+		if entry.Value == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return errors.Wrap(err, "failed to delete value")
+			}
+			continue
+		}
+
+		if err := writeFile(path, entry.Value); err != nil {
+			return errors.Wrap(err, "failed to write value")
+		}
+	}
+
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return errors.Wrap(err, "failed to sync object store")
+		}
+	}
+	return nil
+}
+
+func removeJournal() error {
+	if err := os.Remove(filepath.Join(Dir, journalName)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove journal")
+	}
+	if err := syncDir(Dir); err != nil {
+		return errors.Wrap(err, "failed to remove journal")
+	}
+	return nil
+}
+
+// writeFile writes data through a temporary file synced before the rename,
+// so that the file has either the previous data or the whole data.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// syncDir makes the files created, renamed or removed in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// fileObjectStore is an object store of a transaction.
+type fileObjectStore struct {
+	dir  string
+	mode txMode
+	// writes are the values written in the transaction by their keys,
+	// which are nil if deleted.
+	writes map[string][]byte
+}
+
+func (s *fileObjectStore) path(key string) string {
+	return filepath.Join(s.dir, key+valueExt)
+}
+
+func (s *fileObjectStore) get(ctx context.Context, key string) ([]byte, bool, error) {
+	if err := checkKey(key); err != nil {
+		return nil, false, err
+	}
+
+	if val, ok := s.writes[key]; ok {
+		return val, val != nil, nil
+	}
+
+	val, err := os.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read value")
+	}
+	return val, true, nil
+}
+
+func (s *fileObjectStore) add(ctx context.Context, key string, val []byte) error {
+	_, found, err := s.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if found {
+		return errors.Wrapf(ErrKeyExists, "%q", key)
+	}
+	return s.put(ctx, key, val)
+}
+
+func (s *fileObjectStore) put(ctx context.Context, key string, val []byte) error {
+	if err := s.checkWritable(key); err != nil {
+		return err
+	}
+	s.writes[key] = append([]byte{}, val...)
+	return nil
+}
+
+func (s *fileObjectStore) delete(ctx context.Context, key string) error {
+	if err := s.checkWritable(key); err != nil {
+		return err
+	}
+	s.writes[key] = nil
+	return nil
+}
+
+func (s *fileObjectStore) checkWritable(key string) error {
+	if s.mode != txReadWrite {
+		return errors.New("transaction is read-only")
+	}
+	return checkKey(key)
+}
+
+func (s *fileObjectStore) iterate(ctx context.Context, each func(key string, val []byte) (bool, error)) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read object store")
+	}
+
+	keys := make(map[string]bool, len(entries)+len(s.writes))
+	for _, entry := range entries {
+		if key, ok := strings.CutSuffix(entry.Name(), valueExt); ok {
+			keys[key] = true
+		}
+	}
+	for key := range s.writes {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		val, found, err := s.get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+
+		if doContinue, err := each(key, val); !doContinue || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkKey checks the key can be a file name.
+func checkKey(key string) error {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return errors.Wrapf(ErrInvalidKey, "%q", key)
+	}
+	return nil
+}
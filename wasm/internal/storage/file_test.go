@@ -0,0 +1,146 @@
+//go:build !(js && wasm)
+
+package storage_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
+	"miner/internal/checkpoint"
+	"miner/internal/hash"
+	"miner/internal/storage"
+	"miner/internal/tx"
+)
+
+func initDB(t *testing.T) context.Context {
+	ctx := context.Background()
+	storage.Dir = t.TempDir()
+	require.NoError(t, storage.InitDB(ctx))
+	return ctx
+}
+
+func TestInitDB(t *testing.T) {
+	ctx := initDB(t)
+
+	// reopening keeps the stored genesis.
+	require.NoError(t, storage.InitDB(ctx))
+
+	head, err := storage.FindBlockchainHead()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(blockchain.GenesisHash()), head)
+}
+
+func TestBlockHeaders(t *testing.T) {
+	ctx := initDB(t)
+
+	b, err := block.New([]byte("miner"), nil, 0, blockchain.GenesisHash(), blockchain.Difficulty)
+	require.NoError(t, err)
+	b.Header.CurHash = b.Header.MakeHash()
+
+	require.NoError(t, storage.InsertBlockHeader(ctx, b.Header))
+	assert.ErrorIs(t, storage.InsertBlockHeader(ctx, b.Header), storage.ErrKeyExists)
+
+	found, err := storage.HasBlockHeader(ctx, b.Header.CurHash)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	header, err := storage.FindBlockHeader(ctx, b.Header.CurHash)
+	require.NoError(t, err)
+	assert.Equal(t, b.Header.CurHash, header.CurHash)
+
+	header, err = storage.FindBlockHeader(ctx, hash.Hash("missing"))
+	require.NoError(t, err)
+	assert.Nil(t, header)
+
+	headers, err := storage.FindBlockHeaders(ctx)
+	require.NoError(t, err)
+	assert.Len(t, headers, 2)
+
+	head, err := storage.FindBlockchainHead()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(b.Header.CurHash), head)
+}
+
+func TestMempool(t *testing.T) {
+	ctx := initDB(t)
+
+	txs := []*tx.Transaction{{Hash: []byte("tx1")}, {Hash: []byte("tx2")}}
+	for _, transaction := range txs {
+		require.NoError(t, storage.PutTxToMempool(ctx, transaction))
+	}
+	assert.ErrorIs(t, storage.PutTxToMempool(ctx, txs[0]), storage.ErrKeyExists)
+
+	found, err := storage.FindTxsFromMempool(ctx, []hash.Hash{txs[1].Hash.ToHex()})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, txs[1].Hash, found[0].Hash)
+
+	require.NoError(t, storage.DeleteTxsFromMempool(ctx, []hash.Hash{txs[0].Hash}))
+
+	all, err := storage.FindAllTxsFromMempool(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, txs[1].Hash, all[0].Hash)
+}
+
+func TestCheckpoint(t *testing.T) {
+	ctx := initDB(t)
+
+	cp, err := storage.FindCheckpoint(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+
+	b, err := block.New([]byte("miner"), nil, 0, blockchain.GenesisHash(), blockchain.Difficulty)
+	require.NoError(t, err)
+
+	// a checkpoint replaces the previous one.
+	require.NoError(t, storage.PutCheckpoint(ctx, checkpoint.New(b, 100)))
+	require.NoError(t, storage.PutCheckpoint(ctx, checkpoint.New(b, 200)))
+
+	cp, err = storage.FindCheckpoint(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, cp)
+	assert.Equal(t, uint64(200), cp.NextNonce)
+
+	require.NoError(t, storage.DeleteCheckpoint(ctx))
+
+	cp, err = storage.FindCheckpoint(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, cp)
+}
+
+func TestJournal(t *testing.T) {
+	ctx := initDB(t)
+
+	txs := []*tx.Transaction{{Hash: []byte("tx1")}, {Hash: []byte("tx2")}}
+	require.NoError(t, storage.PutTxToMempool(ctx, txs[0]))
+
+	// the process stopped after the journal of the commit is written.
+	val, err := json.Marshal(txs[1])
+	require.NoError(t, err)
+
+	journal, err := json.Marshal([]map[string]any{
+		{"store": storage.ObjStoreMempool, "key": string(txs[0].Hash.ToHex()), "value": nil},
+		{"store": storage.ObjStoreMempool, "key": string(txs[1].Hash.ToHex()), "value": val},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(storage.Dir, "journal.json"), journal, 0o644))
+
+	// reopening applies the whole transaction.
+	require.NoError(t, storage.InitDB(ctx))
+
+	all, err := storage.FindAllTxsFromMempool(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, txs[1].Hash, all[0].Hash)
+
+	assert.NoFileExists(t, filepath.Join(storage.Dir, "journal.json"))
+}
@@ -0,0 +1,161 @@
+//go:build js && wasm
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"syscall/js"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	errs "github.com/pkg/errors"
+
+	"miner/internal/misc/util"
+)
+
+var db *idb.Database
+
+func openDB(ctx context.Context) error {
+	openRequest, err := idb.Global().Open(ctx, "blockchain", dbVersion, func(db *idb.Database, oldVersion, newVersion uint) error {
+		names, err := db.ObjectStoreNames()
+		if err != nil {
+			return err
+		}
+
+		existing := make(map[string]bool, len(names))
+		for _, name := range names {
+			existing[name] = true
+		}
+
+		for _, name := range objStores {
+			if existing[name] {
+				continue
+			}
+
+			if _, err := db.CreateObjectStore(name, idb.ObjectStoreOptions{}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return errs.Wrap(err, "failed to reqeust open db")
+	}
+
+	d, err := openRequest.Await(ctx)
+	if err != nil {
+		return errs.Wrap(err, "open db request failed")
+	}
+
+	db = d
+	return nil
+}
+
+func withTx(mode txMode, f func(tranx dbTransaction) error, objStoreName string, objStoreNames ...string) error {
+	idbMode := idb.TransactionReadOnly
+	if mode == txReadWrite {
+		idbMode = idb.TransactionReadWrite
+	}
+
+	tranx, err := db.Transaction(idbMode, objStoreName, objStoreNames...)
+	if err != nil {
+		return errs.Wrap(err, "failed to start transaction")
+	}
+
+	if err = f(idbTransaction{tranx}); err != nil {
+		if e := tranx.Abort(); e != nil {
+			return errors.Join(err, e)
+		}
+		return err
+	}
+
+	return tranx.Commit()
+}
+
+type idbTransaction struct {
+	tranx *idb.Transaction
+}
+
+func (t idbTransaction) objectStore(name string) (objectStore, error) {
+	objStore, err := t.tranx.ObjectStore(name)
+	if err != nil {
+		return nil, err
+	}
+	return idbObjectStore{objStore}, nil
+}
+
+type idbObjectStore struct {
+	objStore *idb.ObjectStore
+}
+
+func (s idbObjectStore) get(ctx context.Context, key string) ([]byte, bool, error) {
+	req, err := s.objStore.Get(js.ValueOf(key))
+	if err != nil {
+		return nil, false, err
+	}
+
+	val, err := req.Await(ctx)
+	if err != nil {
+		return nil, false, errs.Wrap(err, "request failed")
+	}
+
+	if val.IsUndefined() {
+		return nil, false, nil
+	}
+	return util.FromJSObject(val), true, nil
+}
+
+func (s idbObjectStore) add(ctx context.Context, key string, val []byte) error {
+	req, err := s.objStore.AddKey(js.ValueOf(key), util.ToJSObject(val))
+	if err != nil {
+		return err
+	}
+
+	if err := req.Await(ctx); err != nil {
+		if errors.Is(err, idb.NewDOMException("ConstraintError")) {
+			return errs.Wrapf(ErrKeyExists, "%q", key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s idbObjectStore) put(ctx context.Context, key string, val []byte) error {
+	req, err := s.objStore.PutKey(js.ValueOf(key), util.ToJSObject(val))
+	if err != nil {
+		return err
+	}
+
+	_, err = req.Await(ctx)
+	return err
+}
+
+func (s idbObjectStore) delete(ctx context.Context, key string) error {
+	req, err := s.objStore.Delete(js.ValueOf(key))
+	if err != nil {
+		return err
+	}
+
+	return req.Await(ctx)
+}
+
+func (s idbObjectStore) iterate(ctx context.Context, each func(key string, val []byte) (bool, error)) error {
+	req, err := s.objStore.OpenCursor(idb.CursorNext)
+	if err != nil {
+		return err
+	}
+
+	return req.Iter(ctx, func(cur *idb.CursorWithValue) error {
+		key, _ := cur.Key()
+		val, _ := cur.Value()
+
+		doContinue, err := each(key.String(), util.FromJSObject(val))
+		if !doContinue || err != nil {
+			return err
+		}
+
+		return cur.Continue()
+	})
+}
@@ -3,9 +3,7 @@ package storage
 import (
 	"context"
 	"encoding/json"
-	"syscall/js"
 
-	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/pkg/errors"
 
 	"miner/internal/hash"
@@ -13,10 +11,11 @@ import (
 	"miner/internal/tx"
 )
 
-// PutTxToMempool puts the transaction to mempool.
+// PutTxToMempool puts the transaction to mempool. It fails with ErrKeyExists
+// if the transaction is already there.
 func PutTxToMempool(ctx context.Context, transaction *tx.Transaction) error {
-	return withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreMempool)
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreMempool)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
@@ -26,12 +25,7 @@ func PutTxToMempool(ctx context.Context, transaction *tx.Transaction) error {
 			return errors.Wrap(err, "failed to marshal transaction")
 		}
 
-		objStore.AddKey(
-			js.ValueOf(util.BytesToStr(transaction.Hash.ToHex())),
-			util.ToJSObject(b),
-		)
-
-		return nil
+		return objStore.add(ctx, util.BytesToStr(transaction.Hash.ToHex()), b)
 	},
 		ObjStoreMempool,
 	)
@@ -39,14 +33,16 @@ func PutTxToMempool(ctx context.Context, transaction *tx.Transaction) error {
 
 // DeleteTxsFromMempool deletes transactions from mempool.
 func DeleteTxsFromMempool(ctx context.Context, txHashes []hash.Hash) error {
-	return withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreMempool)
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreMempool)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
 
 		for _, hash := range txHashes {
-			objStore.Delete(js.ValueOf(util.BytesToStr(hash.ToHex())))
+			if err := objStore.delete(ctx, util.BytesToStr(hash.ToHex())); err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -58,25 +54,24 @@ func DeleteTxsFromMempool(ctx context.Context, txHashes []hash.Hash) error {
 // FindTxsFromMempool finds transactions from mempool.
 func FindTxsFromMempool(ctx context.Context, txHashes []hash.Hash) ([]*tx.Transaction, error) {
 	txs := make([]*tx.Transaction, 0, len(txHashes))
-	err := withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreMempool)
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreMempool)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
 
 		for _, hash := range txHashes {
-			req, _ := objStore.Get(js.ValueOf(util.BytesToStr(hash)))
-			val, err := req.Await(ctx)
+			val, found, err := objStore.get(ctx, util.BytesToStr(hash))
 			if err != nil {
-				return errors.Wrap(err, "request failed")
+				return err
 			}
 
-			if val.IsUndefined() {
+			if !found {
 				return errors.Errorf("value not found with hash: %s", hash)
 			}
 
 			var dst tx.Transaction
-			if err := json.Unmarshal(util.FromJSObject(val), &dst); err != nil {
+			if err := json.Unmarshal(val, &dst); err != nil {
 				return errors.Wrap(err, "failed to unmarshal transaction")
 			}
 
@@ -94,3 +89,32 @@ func FindTxsFromMempool(ctx context.Context, txHashes []hash.Hash) ([]*tx.Transa
 
 	return txs, nil
 }
+
+// FindAllTxsFromMempool finds every transaction in mempool.
+func FindAllTxsFromMempool(ctx context.Context) ([]*tx.Transaction, error) {
+	txs := make([]*tx.Transaction, 0)
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreMempool)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		return objStore.iterate(ctx, func(_ string, val []byte) (bool, error) {
+			var dst tx.Transaction
+			if err := json.Unmarshal(val, &dst); err != nil {
+				return false, errors.Wrap(err, "failed to unmarshal transaction")
+			}
+
+			txs = append(txs, &dst)
+			return true, nil
+		})
+	},
+		ObjStoreMempool,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
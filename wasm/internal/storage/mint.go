@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/issuance"
+	"miner/internal/misc/util"
+)
+
+// InsertMint inserts the record of an admin mint.
+func InsertMint(ctx context.Context, mint *issuance.Mint) error {
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreMint)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		b, err := json.Marshal(mint)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal mint")
+		}
+
+		return objStore.put(ctx, util.BytesToStr(mint.TxHash.ToHex()), b)
+	}, ObjStoreMint)
+}
+
+// FindMints finds every record of the admin mints ordered by the mint time.
+func FindMints(ctx context.Context) ([]*issuance.Mint, error) {
+	mints := make([]*issuance.Mint, 0)
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreMint)
+		if err != nil {
+			return errors.Wrap(err, "failed to get object store")
+		}
+
+		return objStore.iterate(ctx, func(_ string, val []byte) (bool, error) {
+			var dst issuance.Mint
+			if err := json.Unmarshal(val, &dst); err != nil {
+				return false, errors.Wrap(err, "failed to unmarshal mint")
+			}
+
+			mints = append(mints, &dst)
+			return true, nil
+		})
+	}, ObjStoreMint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(mints, func(i, j int) bool {
+		return mints[i].MintedAt.Before(mints[j].MintedAt)
+	})
+
+	return mints, nil
+}
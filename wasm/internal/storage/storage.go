@@ -2,14 +2,12 @@ package storage
 
 import (
 	"context"
-	"errors"
-	"miner/internal/block"
-	"miner/internal/blockchain"
-	"syscall/js"
 	"time"
 
-	"github.com/hack-pad/go-indexeddb/idb"
-	errs "github.com/pkg/errors"
+	"github.com/pkg/errors"
+
+	"miner/internal/block"
+	"miner/internal/blockchain"
 )
 
 const (
@@ -17,86 +15,71 @@ const (
 	ObjStoreBlockBody   = "blockBodies"
 	ObjStoreBlockHeader = "blockHeaders"
 	ObjStoreMempool     = "mempool"
+	ObjStoreMint        = "mints"
+	ObjStoreCheckpoint  = "checkpoints"
 )
 
-var db *idb.Database
-
-func InitDB(ctx context.Context) error {
-	openRequest, err := idb.Global().Open(ctx, "blockchain", 0, func(db *idb.Database, oldVersion, newVersion uint) error {
-		_, err := db.CreateObjectStore(ObjStoreTransaction, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-		_, err = db.CreateObjectStore(ObjStoreBlockBody, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-		_, err = db.CreateObjectStore(ObjStoreBlockHeader, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-		_, err = db.CreateObjectStore(ObjStoreMempool, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return errs.Wrap(err, "failed to reqeust open db")
-	}
+// dbVersion should be increased whenever a new object store is added.
+const dbVersion = 3
 
-	d, err := openRequest.Await(ctx)
-	if err != nil {
-		return errs.Wrap(err, "open db request failed")
-	}
+var objStores = []string{
+	ObjStoreTransaction,
+	ObjStoreBlockBody,
+	ObjStoreBlockHeader,
+	ObjStoreMempool,
+	ObjStoreMint,
+	ObjStoreCheckpoint,
+}
 
-	db = d
+var ErrKeyExists = errors.New("key already exists")
 
-	b := &block.Block{
-		Header: &block.Header{
-			CurHash:    blockchain.GenesisHash(),
-			PrevHash:   blockchain.GenesisHash(),
-			DataHash:   blockchain.GenesisHash(),
-			Difficulty: 0,
-			Nonce:      0,
-			Timestamp:  time.Time{},
-		},
-	}
+// txMode is the mode of a transaction.
+type txMode int
 
-	return InsertBlockHeader(ctx, b.Header)
+const (
+	txReadOnly txMode = iota
+	txReadWrite
+)
 
+// dbTransaction is a transaction of the backend, IndexedDB in the browser and
+// the files of Dir elsewhere, over the object stores it is started with.
+type dbTransaction interface {
+	objectStore(name string) (objectStore, error)
 }
 
-func withTx(mode idb.TransactionMode, f func(tranx *idb.Transaction) error, objStoreName string, objStoreNames ...string) error {
-	tranx, err := db.Transaction(mode, objStoreName, objStoreNames...)
-	if err != nil {
-		return errs.Wrap(err, "failed to start transaction")
-	}
+// objectStore holds the JSON values by their string keys.
+type objectStore interface {
+	// get returns the value of key. found is false if there is none.
+	get(ctx context.Context, key string) (val []byte, found bool, err error)
+	// add inserts the value of key, and fails with ErrKeyExists if there is one.
+	add(ctx context.Context, key string, val []byte) error
+	// put inserts or replaces the value of key.
+	put(ctx context.Context, key string, val []byte) error
+	delete(ctx context.Context, key string) error
+	// iterate calls each with the values in the order of their keys until it
+	// returns false or an error.
+	iterate(ctx context.Context, each func(key string, val []byte) (bool, error)) error
+}
 
-	if err = f(tranx); err != nil {
-		if e := tranx.Abort(); err != nil {
-			return errors.Join(err, e)
-		}
+// InitDB opens the database, and stores the genesis header if it is new.
+func InitDB(ctx context.Context) error {
+	if err := openDB(ctx); err != nil {
 		return err
 	}
 
-	return tranx.Commit()
-}
-
-func iterate(ctx context.Context, objStore *idb.ObjectStore, each func(key, val js.Value) (bool, error)) error {
-	req, _ := objStore.OpenCursor(idb.CursorNext)
-
-	return req.Iter(ctx, func(cur *idb.CursorWithValue) error {
-		key, _ := cur.Key()
-		val, _ := cur.Value()
+	genesis := &block.Header{
+		CurHash:    blockchain.GenesisHash(),
+		PrevHash:   blockchain.GenesisHash(),
+		DataHash:   blockchain.GenesisHash(),
+		Difficulty: 0,
+		Nonce:      0,
+		Timestamp:  time.Time{},
+	}
 
-		doContinue, err := each(key, val)
-		if !doContinue || err != nil {
-			return err
-		}
+	found, err := HasBlockHeader(ctx, genesis.CurHash)
+	if err != nil || found {
+		return err
+	}
 
-		return cur.Continue()
-	})
+	return InsertBlockHeader(ctx, genesis)
 }
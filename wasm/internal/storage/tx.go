@@ -3,9 +3,7 @@ package storage
 import (
 	"context"
 	"encoding/json"
-	"syscall/js"
 
-	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/pkg/errors"
 
 	"miner/internal/hash"
@@ -16,19 +14,18 @@ import (
 // FindTx finds a transaction from the database.
 func FindTx(ctx context.Context, txHash hash.Hash) (*tx.Transaction, error) {
 	var dst tx.Transaction
-	err := withTx(idb.TransactionReadOnly, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreTransaction)
+	err := withTx(txReadOnly, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreTransaction)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
 
-		req, _ := objStore.Get(js.ValueOf(util.BytesToStr(txHash.ToHex())))
-		val, err := req.Await(ctx)
+		val, _, err := objStore.get(ctx, util.BytesToStr(txHash.ToHex()))
 		if err != nil {
-			return errors.Wrap(err, "request failed")
+			return err
 		}
 
-		if err := json.Unmarshal(util.FromJSObject(val), &dst); err != nil {
+		if err := json.Unmarshal(val, &dst); err != nil {
 			return errors.Wrap(err, "failed to unmarshal transaction")
 		}
 
@@ -46,8 +43,8 @@ func FindTx(ctx context.Context, txHash hash.Hash) (*tx.Transaction, error) {
 
 // InsertTxs inserts transactions.
 func InsertTxs(ctx context.Context, txs []*tx.Transaction) error {
-	return withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreTransaction)
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreTransaction)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
@@ -58,18 +55,7 @@ func InsertTxs(ctx context.Context, txs []*tx.Transaction) error {
 				return errors.Wrap(err, "failed to marshal transaction")
 			}
 
-			js.Global().Get("console").Call("log", util.BytesToStr(b))
-
-			req, err := objStore.AddKey(
-				js.ValueOf(util.BytesToStr(tx.Hash.ToHex())),
-				util.ToJSObject(b),
-			)
-
-			if err != nil {
-				return err
-			}
-
-			if err := req.Await(ctx); err != nil {
+			if err := objStore.add(ctx, util.BytesToStr(tx.Hash.ToHex()), b); err != nil {
 				return err
 			}
 		}
@@ -80,14 +66,16 @@ func InsertTxs(ctx context.Context, txs []*tx.Transaction) error {
 
 // DeleteTxs deletes transactions.
 func DeleteTxs(ctx context.Context, txHashes [][]byte) error {
-	return withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreTransaction)
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreTransaction)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
 
 		for _, hash := range txHashes {
-			objStore.Delete(js.ValueOf(util.BytesToStr(util.EncodeHex(hash))))
+			if err := objStore.delete(ctx, util.BytesToStr(util.EncodeHex(hash))); err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -96,8 +84,8 @@ func DeleteTxs(ctx context.Context, txHashes [][]byte) error {
 
 // UpdateTxs deletes transactions.
 func UpdateTxs(ctx context.Context, txs []*tx.Transaction) error {
-	return withTx(idb.TransactionReadWrite, func(tranx *idb.Transaction) error {
-		objStore, err := tranx.ObjectStore(ObjStoreTransaction)
+	return withTx(txReadWrite, func(tranx dbTransaction) error {
+		objStore, err := tranx.objectStore(ObjStoreTransaction)
 		if err != nil {
 			return errors.Wrap(err, "failed to get object store")
 		}
@@ -108,10 +96,9 @@ func UpdateTxs(ctx context.Context, txs []*tx.Transaction) error {
 				return errors.Wrap(err, "failed to marshal transaction")
 			}
 
-			objStore.PutKey(
-				js.ValueOf(util.BytesToStr(tx.Hash.ToHex())),
-				util.ToJSObject(b),
-			)
+			if err := objStore.put(ctx, util.BytesToStr(tx.Hash.ToHex()), b); err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -0,0 +1,104 @@
+package tx
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"miner/internal/misc/wire"
+)
+
+// EncodingVersion is the version of the transaction encoding.
+const EncodingVersion = 2
+
+// Encode returns the canonical encoding of the transaction.
+//
+//	version    uint8, EncodingVersion
+//	createdAt  int64, unix time in nanoseconds
+//	nonce      uint64
+//	inputs     count, and for each input:
+//	  txHash   bytes
+//	  outIdx   uint16
+//	  sig      bytes
+//	outputs    count, and for each output:
+//	  addr     bytes
+//	  amount   uint64
+//
+// The hash is not encoded since it is derived from the encoding.
+func (tx *Transaction) Encode() []byte {
+	var w wire.Writer
+
+	w.Uint8(EncodingVersion)
+	w.Int64(tx.CreatedAt.UnixNano())
+	w.Uint64(tx.Nonce)
+
+	w.Count(len(tx.Inputs))
+	for _, in := range tx.Inputs {
+		w.Bytes(in.TxHash)
+		w.Uint16(in.OutIdx)
+		w.Bytes(in.Signature)
+	}
+
+	w.Count(len(tx.Outputs))
+	for _, out := range tx.Outputs {
+		w.Bytes(out.Addr)
+		w.Uint64(out.Amount)
+	}
+
+	return w.Result()
+}
+
+// Decode decodes the canonical encoding of a transaction and fills its hash.
+func Decode(b []byte) (*Transaction, error) {
+	r := wire.NewReader(b)
+
+	tx, err := DecodeFrom(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Finish(); err != nil {
+		return nil, errors.Wrap(err, "failed to decode transaction")
+	}
+
+	return tx, nil
+}
+
+// DecodeFrom decodes a transaction from r, which may hold more data after it.
+func DecodeFrom(r *wire.Reader) (*Transaction, error) {
+	if v := r.Uint8(); r.Err() == nil && v != EncodingVersion {
+		return nil, errors.Errorf("unknown transaction encoding version: %d", v)
+	}
+
+	tx := &Transaction{
+		CreatedAt: time.Unix(0, r.Int64()),
+		Nonce:     r.Uint64(),
+	}
+
+	// input takes at least 4 bytes: two empty bytes and outIdx.
+	tx.Inputs = make([]*TxInput, r.Count(4))
+	for i := range tx.Inputs {
+		tx.Inputs[i] = &TxInput{
+			TxHash:    r.Bytes(),
+			OutIdx:    r.Uint16(),
+			Signature: r.Bytes(),
+		}
+	}
+
+	// output takes at least 9 bytes: empty addr and amount.
+	tx.Outputs = make([]*TxOutput, r.Count(9))
+	for i := range tx.Outputs {
+		tx.Outputs[i] = &TxOutput{
+			Addr:   r.Bytes(),
+			Amount: r.Uint64(),
+		}
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to decode transaction")
+	}
+
+	tx.Hash, _ = tx.MakeHash()
+
+	return tx, nil
+}
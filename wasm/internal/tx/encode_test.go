@@ -0,0 +1,75 @@
+package tx_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"miner/internal/tx"
+)
+
+// test vector of the canonical transaction encoding.
+const (
+	txEncodingHex = "02" + // version
+		"00002a36fe9c9717" + // createdAt
+		"0700000000000000" + // nonce
+		"01" + // input count
+		"02aabb" + "0100" + "03010203" + // txHash, outIdx, sig
+		"01" + // output count
+		"01cc" + "0a00000000000000" // addr, amount
+	txHashHex = "6aceb0c3e416ecdc767db66617f2379a5fb6200f24f38f6b5c1994c0b5bde6c4"
+)
+
+func TestEncode(t *testing.T) {
+	transaction := &tx.Transaction{
+		CreatedAt: time.Unix(0, 1700000000000000000),
+		Nonce:     7,
+		Inputs: []*tx.TxInput{{
+			TxHash:    []byte{0xaa, 0xbb},
+			OutIdx:    1,
+			Signature: []byte{0x01, 0x02, 0x03},
+		}},
+		Outputs: []*tx.TxOutput{{
+			Addr:   []byte{0xcc},
+			Amount: 10,
+		}},
+	}
+
+	assert.Equal(t, txEncodingHex, hex.EncodeToString(transaction.Encode()))
+
+	h, err := transaction.MakeHash()
+	require.NoError(t, err)
+	assert.Equal(t, txHashHex, hex.EncodeToString(h))
+}
+
+func TestDecode(t *testing.T) {
+	b, _ := hex.DecodeString(txEncodingHex)
+
+	t.Run("ok", func(t *testing.T) {
+		transaction, err := tx.Decode(b)
+		require.NoError(t, err)
+
+		assert.Equal(t, txHashHex, hex.EncodeToString(transaction.Hash))
+		assert.True(t, transaction.CreatedAt.Equal(time.Unix(0, 1700000000000000000)))
+		assert.Equal(t, uint64(7), transaction.Nonce)
+		assert.Equal(t, b, transaction.Encode())
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, err := tx.Decode(b[:len(b)-1])
+		assert.Error(t, err)
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		_, err := tx.Decode(append(b, 0x00))
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		_, err := tx.Decode(append([]byte{0x01}, b[1:]...))
+		assert.Error(t, err)
+	})
+}
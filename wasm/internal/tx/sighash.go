@@ -0,0 +1,49 @@
+package tx
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+
+	"github.com/pkg/errors"
+)
+
+// SigHash returns the hash which the signatures of the inputs sign.
+//
+// Its preimage is the canonical encoding of the transaction with every
+// signature left empty, so it commits to createdAt, all the inputs' outpoints
+// and all the outputs with their amounts. Changing any of them makes
+// every signature invalid.
+func (tx *Transaction) SigHash() []byte {
+	unsigned := *tx
+	unsigned.Inputs = make([]*TxInput, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		unsigned.Inputs[i] = &TxInput{TxHash: in.TxHash, OutIdx: in.OutIdx}
+	}
+
+	sum := sha256.Sum256(unsigned.Encode())
+	return sum[:]
+}
+
+// Sign signs every input with privKey. Inputs and outputs should not be
+// modified after signing.
+func (tx *Transaction) Sign(privKey *ecdsa.PrivateKey) error {
+	sigHash := tx.SigHash()
+	for _, in := range tx.Inputs {
+		signature, err := ecdsa.SignASN1(rand.Reader, privKey, sigHash)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign")
+		}
+		in.Signature = signature
+	}
+	return nil
+}
+
+// VerifyInput verifies the signature of idx-th input with the public key
+// of the output it spends.
+func (tx *Transaction) VerifyInput(idx int, publicKey *ecdsa.PublicKey) bool {
+	if idx < 0 || idx >= len(tx.Inputs) {
+		return false
+	}
+	return ecdsa.VerifyASN1(publicKey, tx.SigHash(), tx.Inputs[idx].Signature)
+}
@@ -3,10 +3,7 @@ package tx
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/sha256"
-	"encoding/binary"
-	"encoding/gob"
 	"time"
 
 	"github.com/cbergoon/merkletree"
@@ -15,7 +12,15 @@ import (
 	"miner/internal/hash"
 )
 
-var COINBASE hash.Hash = []byte("COINBASE")
+var (
+	COINBASE hash.Hash = []byte("COINBASE")
+	// ADMIN marks the input of an admin mint transaction.
+	ADMIN hash.Hash = []byte("ADMIN")
+	// SPENT replaces the address of a stored output once it is spent.
+	SPENT hash.Hash = []byte{0x00}
+)
+
+var ErrNotEnoughCoins = errors.New("not enough coins")
 
 // TxInput is used in transaction to determine which transaction output
 // is used in this transaction.
@@ -25,15 +30,6 @@ type TxInput struct {
 	Signature hash.Hash `json:"sig"`
 }
 
-func (in *TxInput) GetDataBytes() []byte {
-	buf := bytes.NewBuffer(nil)
-
-	buf.Write(in.TxHash)
-	binary.Write(buf, binary.LittleEndian, in.OutIdx)
-
-	return buf.Bytes()
-}
-
 // TxOutput is result of the transaction.
 type TxOutput struct {
 	Addr   hash.Hash `json:"addr"`
@@ -51,11 +47,14 @@ type UTxOutput struct {
 type Transaction struct {
 	Hash      hash.Hash   `json:"hash"`
 	CreatedAt time.Time   `json:"createdAt"`
+	Nonce     uint64      `json:"nonce,omitempty"`
 	Inputs    []*TxInput  `json:"inputs"`
 	Outputs   []*TxOutput `json:"outputs"`
 }
 
-func New(uTxOuts []*UTxOutput, amount uint64, privKey *ecdsa.PrivateKey, srcAddr []byte, dstAddr []byte) (*Transaction, error) {
+// New creates the transaction sending amount of uTxOuts to dstAddr, with the
+// change back to srcAddr. fee is not spent by the outputs, so the miner gets it.
+func New(uTxOuts []*UTxOutput, amount, fee uint64, privKey *ecdsa.PrivateKey, srcAddr []byte, dstAddr []byte) (*Transaction, error) {
 	tx := &Transaction{
 		Inputs:  make([]*TxInput, 0, len(uTxOuts)),
 		Outputs: make([]*TxOutput, 0),
@@ -63,29 +62,27 @@ func New(uTxOuts []*UTxOutput, amount uint64, privKey *ecdsa.PrivateKey, srcAddr
 
 	var sum uint64
 	for _, out := range uTxOuts {
-		input := &TxInput{
+		tx.Inputs = append(tx.Inputs, &TxInput{
 			TxHash: out.TxHash,
 			OutIdx: out.OutIdx,
-		}
-
-		b := input.GetDataBytes()
-		signature, err := ecdsa.SignASN1(rand.Reader, privKey, b)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to sign")
-		}
-
-		input.Signature = signature
-
-		tx.Inputs = append(tx.Inputs, input)
+		})
 		sum += out.Amount
 	}
 
+	if amount+fee < amount || sum < amount+fee {
+		return nil, errors.Wrapf(ErrNotEnoughCoins, "got: %d, need: %d with fee %d", sum, amount, fee)
+	}
+
 	dstOut := &TxOutput{Addr: dstAddr, Amount: amount}
-	srcOut := &TxOutput{Addr: srcAddr, Amount: sum - amount}
+	srcOut := &TxOutput{Addr: srcAddr, Amount: sum - amount - fee}
 
 	tx.Outputs = append(tx.Outputs, dstOut, srcOut)
 	tx.CreatedAt = time.Now()
 
+	if err := tx.Sign(privKey); err != nil {
+		return nil, err
+	}
+
 	hash, err := tx.MakeHash()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to make hash of tx")
@@ -96,37 +93,63 @@ func New(uTxOuts []*UTxOutput, amount uint64, privKey *ecdsa.PrivateKey, srcAddr
 	return tx, nil
 }
 
-func (tx *Transaction) ValidateHash() bool {
-	hash, err := tx.MakeHash()
-	return err == nil && bytes.Equal(tx.Hash, hash)
-}
+// NewMint creates an admin mint transaction which issues amount of new coins
+// to dstAddr. nonce should be unique among every mint, so that the transaction
+// cannot be replayed.
+func NewMint(adminKey *ecdsa.PrivateKey, dstAddr []byte, amount uint64, nonce uint64) (*Transaction, error) {
+	tx := &Transaction{
+		CreatedAt: time.Now(),
+		Nonce:     nonce,
+		Inputs:    []*TxInput{{TxHash: ADMIN, OutIdx: 0}},
+		Outputs:   []*TxOutput{{Addr: dstAddr, Amount: amount}},
+	}
 
-func (tx *Transaction) MakeHash() ([]byte, error) {
-	hash := sha256.New()
-	buf := bytes.NewBuffer(nil)
-	enc := gob.NewEncoder(buf)
+	if err := tx.Sign(adminKey); err != nil {
+		return nil, err
+	}
 
-	if err := enc.Encode(tx.Inputs); err != nil {
-		return nil, errors.Wrap(err, "failed to encode tx inputs")
+	hash, err := tx.MakeHash()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make hash of tx")
 	}
 
-	hash.Write(buf.Bytes())
-	buf.Reset()
+	tx.Hash = hash
+
+	return tx, nil
+}
 
-	if err := enc.Encode(tx.Outputs); err != nil {
-		return nil, errors.Wrap(err, "failed to encode tx outputs")
-	}
+// IsCoinbase reports whether the transaction is a coinbase transaction.
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.Inputs) > 0 && bytes.Equal(tx.Inputs[0].TxHash, COINBASE)
+}
 
-	hash.Write(buf.Bytes())
-	buf.Reset()
+// IsMint reports whether the transaction is an admin mint transaction.
+func (tx *Transaction) IsMint() bool {
+	return len(tx.Inputs) > 0 && bytes.Equal(tx.Inputs[0].TxHash, ADMIN)
+}
 
-	if err := enc.Encode(tx.CreatedAt); err != nil {
-		return nil, errors.Wrap(err, "failed to encode createdAt")
+// OutputSum returns the sum of the outputs' amount.
+func (tx *Transaction) OutputSum() (sum uint64) {
+	for _, out := range tx.Outputs {
+		sum += out.Amount
 	}
+	return
+}
+
+func (tx *Transaction) ValidateHash() bool {
+	hash, err := tx.MakeHash()
+	return err == nil && bytes.Equal(tx.Hash, hash)
+}
 
-	hash.Write(buf.Bytes())
+// MakeHash returns SHA-256 of the canonical encoding of the transaction.
+func (tx *Transaction) MakeHash() ([]byte, error) {
+	sum := sha256.Sum256(tx.Encode())
+	return sum[:], nil
+}
 
-	return hash.Sum(nil), nil
+// Size returns the size of the canonical encoding of the transaction.
+func (tx *Transaction) Size() int {
+	return len(tx.Encode())
 }
 
 func (tx *Transaction) CalculateHash() ([]byte, error) {
@@ -4,6 +4,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"miner/internal/hash"
 	"miner/internal/tx"
 	"testing"
 
@@ -28,17 +29,13 @@ func TestNewTx(t *testing.T) {
 		},
 	}
 
-	tx, err := tx.New(uTxOuts, spent, privKey, []byte("helloThere"), []byte("hithere"))
+	tx, err := tx.New(uTxOuts, spent, 0, privKey, []byte("helloThere"), []byte("hithere"))
 	if assert.NoError(t, err) {
 		// there is one input.
 		assert.Len(t, tx.Inputs, 1)
 
-		in := tx.Inputs[0]
-		data := in.GetDataBytes()
-
 		// signature is valid.
-		valid := ecdsa.VerifyASN1(&privKey.PublicKey, data, in.Signature)
-		assert.True(t, valid)
+		assert.True(t, tx.VerifyInput(0, &privKey.PublicKey))
 
 		// there is diff.
 		assert.Len(t, tx.Outputs, 2)
@@ -47,10 +44,97 @@ func TestNewTx(t *testing.T) {
 		srcOut := tx.Outputs[1]
 
 		// dstOut is ok.
-		assert.Equal(t, []byte("hithere"), dstOut.Addr)
+		assert.Equal(t, hash.Hash("hithere"), dstOut.Addr)
 		assert.Equal(t, uint64(30), dstOut.Amount)
 
 		// srcOut is ok.
 		assert.Equal(t, srcOut.Amount, uint64(got-spent))
 	}
 }
+
+func TestNewTxFee(t *testing.T) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	uTxOuts := []*tx.UTxOutput{{TxHash: []byte("first"), OutIdx: 0, Amount: 40}}
+
+	// the fee is left out of the change.
+	tranx, err := tx.New(uTxOuts, 30, 4, privKey, []byte("src"), []byte("dst"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), tranx.Outputs[1].Amount)
+	assert.Equal(t, uint64(36), tranx.OutputSum())
+
+	_, err = tx.New(uTxOuts, 30, 11, privKey, []byte("src"), []byte("dst"))
+	assert.ErrorIs(t, err, tx.ErrNotEnoughCoins)
+}
+
+func TestSigHash(t *testing.T) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	uTxOuts := []*tx.UTxOutput{
+		{TxHash: []byte("first"), OutIdx: 0, Amount: 10},
+		{TxHash: []byte("second"), OutIdx: 1, Amount: 20},
+	}
+
+	newTx := func() *tx.Transaction {
+		tranx, err := tx.New(uTxOuts, 25, 0, privKey, []byte("src"), []byte("dst"))
+		require.NoError(t, err)
+		return tranx
+	}
+
+	t.Run("every input is signed", func(t *testing.T) {
+		tranx := newTx()
+		assert.True(t, tranx.VerifyInput(0, &privKey.PublicKey))
+		assert.True(t, tranx.VerifyInput(1, &privKey.PublicKey))
+		assert.False(t, tranx.VerifyInput(2, &privKey.PublicKey))
+	})
+
+	t.Run("redirected output", func(t *testing.T) {
+		tranx := newTx()
+		tranx.Outputs[0].Addr = []byte("thief")
+		assert.False(t, tranx.VerifyInput(0, &privKey.PublicKey))
+	})
+
+	t.Run("changed amount", func(t *testing.T) {
+		tranx := newTx()
+		tranx.Outputs[0].Amount--
+		tranx.Outputs[1].Amount++
+		assert.False(t, tranx.VerifyInput(0, &privKey.PublicKey))
+	})
+
+	t.Run("dropped input", func(t *testing.T) {
+		tranx := newTx()
+		tranx.Inputs = tranx.Inputs[:1]
+		assert.False(t, tranx.VerifyInput(0, &privKey.PublicKey))
+	})
+
+	t.Run("signatures are not committed", func(t *testing.T) {
+		tranx := newTx()
+		sigHash := tranx.SigHash()
+		tranx.Inputs[0].Signature = []byte("other")
+		assert.Equal(t, sigHash, tranx.SigHash())
+	})
+}
+
+func TestNewMint(t *testing.T) {
+	adminKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	mint, err := tx.NewMint(adminKey, []byte("dst"), 100, 42)
+	require.NoError(t, err)
+
+	assert.True(t, mint.IsMint())
+	assert.False(t, mint.IsCoinbase())
+	assert.Equal(t, uint64(100), mint.OutputSum())
+	assert.True(t, mint.VerifyInput(0, &adminKey.PublicKey))
+
+	// signature of a mint cannot be reused for another nonce or output.
+	replayed := *mint
+	replayed.Nonce++
+	assert.False(t, replayed.VerifyInput(0, &adminKey.PublicKey))
+
+	replayed = *mint
+	replayed.Outputs = []*tx.TxOutput{{Addr: []byte("thief"), Amount: 100}}
+	assert.False(t, replayed.VerifyInput(0, &adminKey.PublicKey))
+}
@@ -0,0 +1,182 @@
+// Package versionbits implements deployment of consensus rule changes
+// signalled by the version of block headers.
+//
+// A block signals readiness for a deployment by setting the deployment's bit
+// in its version, together with TopBits. Blocks are grouped in windows of
+// Params.Window blocks. When at least Params.Threshold blocks of a window
+// signal for a deployment, it is locked in, and the rule becomes active from
+// the block after the next window.
+package versionbits
+
+import (
+	"sync"
+)
+
+const (
+	// TopBits should be set in the version of every block.
+	TopBits uint32 = 0x20000000
+	// TopMask selects the bits compared with TopBits.
+	TopMask uint32 = 0xe0000000
+	// MaxBit is the highest bit a deployment can use.
+	MaxBit = 28
+)
+
+// State is the state of a deployment.
+type State uint8
+
+const (
+	// Defined deployment is waiting for enough signals.
+	Defined State = iota
+	// LockedIn deployment becomes active after the current window.
+	LockedIn
+	// Active deployment's rule is enforced.
+	Active
+)
+
+func (s State) String() string {
+	switch s {
+	case Defined:
+		return "defined"
+	case LockedIn:
+		return "locked_in"
+	case Active:
+		return "active"
+	}
+	return "unknown"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Deployment is a named rule change.
+type Deployment struct {
+	Name string `json:"name"`
+	Bit  uint8  `json:"bit"`
+}
+
+// Mask returns the version bit of the deployment.
+func (d Deployment) Mask() uint32 {
+	return 1 << d.Bit
+}
+
+// Params are the parameters of the deployments.
+type Params struct {
+	Window      uint64
+	Threshold   uint64
+	Deployments []Deployment
+}
+
+// Signals reports whether version signals for the deployment.
+func Signals(version uint32, d Deployment) bool {
+	return version&TopMask == TopBits && version&d.Mask() != 0
+}
+
+// Status is the state of a deployment at the tip of the chain.
+type Status struct {
+	Deployment
+	State State `json:"state"`
+	// Count is the number of the signalling blocks in the current window.
+	Count uint64 `json:"count"`
+}
+
+// Tracker follows the versions of the connected blocks
+// and the state of each deployment.
+type Tracker struct {
+	mu     sync.Mutex
+	params Params
+	height uint64
+	counts []uint64
+	states []State
+}
+
+func NewTracker(params Params) *Tracker {
+	return &Tracker{
+		params: params,
+		counts: make([]uint64, len(params.Deployments)),
+		states: make([]State, len(params.Deployments)),
+	}
+}
+
+// Connect records the version of the next block of the chain.
+func (t *Tracker) Connect(version uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.height++
+
+	for i, d := range t.params.Deployments {
+		if t.states[i] == Defined && Signals(version, d) {
+			t.counts[i]++
+		}
+	}
+
+	if t.params.Window == 0 || t.height%t.params.Window != 0 {
+		return
+	}
+
+	// the window is finished.
+	for i := range t.params.Deployments {
+		switch {
+		case t.states[i] == LockedIn:
+			t.states[i] = Active
+		case t.states[i] == Defined && t.counts[i] >= t.params.Threshold:
+			t.states[i] = LockedIn
+		}
+		t.counts[i] = 0
+	}
+}
+
+// Height returns the number of the connected blocks.
+func (t *Tracker) Height() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.height
+}
+
+// State returns the state of the deployment for the next block.
+// Unknown deployment is always Defined.
+func (t *Tracker) State(name string) State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, d := range t.params.Deployments {
+		if d.Name == name {
+			return t.states[i]
+		}
+	}
+	return Defined
+}
+
+// Active reports whether the rule of the deployment applies to the next block.
+func (t *Tracker) Active(name string) bool {
+	return t.State(name) == Active
+}
+
+// Version returns the version the next block should have. It signals for
+// every deployment which is not active yet.
+func (t *Tracker) Version() uint32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	version := TopBits
+	for i, d := range t.params.Deployments {
+		if t.states[i] != Active {
+			version |= d.Mask()
+		}
+	}
+	return version
+}
+
+// Statuses returns the status of every deployment.
+func (t *Tracker) Statuses() []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := make([]Status, 0, len(t.params.Deployments))
+	for i, d := range t.params.Deployments {
+		statuses = append(statuses, Status{Deployment: d, State: t.states[i], Count: t.counts[i]})
+	}
+	return statuses
+}
@@ -0,0 +1,60 @@
+package versionbits_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"miner/internal/versionbits"
+)
+
+func TestTracker(t *testing.T) {
+	foo := versionbits.Deployment{Name: "foo", Bit: 0}
+	bar := versionbits.Deployment{Name: "bar", Bit: 1}
+
+	tracker := versionbits.NewTracker(versionbits.Params{
+		Window:      4,
+		Threshold:   3,
+		Deployments: []versionbits.Deployment{foo, bar},
+	})
+
+	signalFoo := versionbits.TopBits | foo.Mask()
+	assert.Equal(t, versionbits.TopBits|foo.Mask()|bar.Mask(), tracker.Version())
+
+	t.Run("not enough signals", func(t *testing.T) {
+		for _, v := range []uint32{signalFoo, signalFoo, versionbits.TopBits, versionbits.TopBits} {
+			tracker.Connect(v)
+		}
+		assert.Equal(t, versionbits.Defined, tracker.State("foo"))
+	})
+
+	t.Run("signal without top bits", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			tracker.Connect(foo.Mask())
+		}
+		assert.Equal(t, versionbits.Defined, tracker.State("foo"))
+	})
+
+	t.Run("locked in", func(t *testing.T) {
+		for _, v := range []uint32{signalFoo, versionbits.TopBits, signalFoo, signalFoo} {
+			tracker.Connect(v)
+			assert.False(t, tracker.Active("foo"))
+		}
+		assert.Equal(t, versionbits.LockedIn, tracker.State("foo"))
+		assert.Equal(t, versionbits.Defined, tracker.State("bar"))
+	})
+
+	t.Run("active after the next window", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			tracker.Connect(versionbits.TopBits)
+			assert.Equal(t, versionbits.LockedIn, tracker.State("foo"))
+		}
+		tracker.Connect(versionbits.TopBits)
+
+		assert.True(t, tracker.Active("foo"))
+		assert.Equal(t, uint64(16), tracker.Height())
+		assert.Equal(t, versionbits.TopBits|bar.Mask(), tracker.Version())
+	})
+
+	assert.Equal(t, versionbits.Defined, tracker.State("unknown"))
+}